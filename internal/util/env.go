@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var ErrEnvNotSet = errors.New("environment variable not set")
@@ -47,6 +48,13 @@ func GetEnv[T any](key string) (*T, error) {
 		}
 		resolved := any(value).(T)
 		return &resolved, nil
+	case time.Duration:
+		value, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s=%q as duration: %w", key, raw, err)
+		}
+		resolved := any(value).(T)
+		return &resolved, nil
 	case []string:
 		values := strings.Fields(raw)
 		if len(values) == 0 {
@@ -28,36 +28,6 @@ func NormalizeExePath(path string) string {
 	return path
 }
 
-func ContainsMainGo(dir string) bool {
-	mainGoPath := filepath.Join(dir, "main.go")
-	info, err := os.Stat(mainGoPath)
-	if err != nil {
-		return false
-	}
-	return !info.IsDir()
-}
-
-func FindMainGoFile(binaryPath string) (string, error) {
-	var retPath string
-	err := filepath.Walk(binaryPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		if filepath.Base(path) != "main.go" {
-			return nil
-		}
-		retPath = path
-		return nil
-	})
-	if err != nil {
-		return "", err
-	}
-	return retPath, nil
-}
-
 func FindGoModDir(startDir string) string {
 	dir := startDir
 	for {
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+)
+
+// BuildContext returns a go/build context that evaluates build constraints like go build does for
+// the target platform, the cgo setting and the build tags.
+func BuildContext(goos, goarch string, cgoEnabled bool, tags []string) *build.Context {
+	ctx := build.Default
+	ctx.GOOS = goos
+	ctx.GOARCH = goarch
+	ctx.CgoEnabled = cgoEnabled
+	ctx.BuildTags = append([]string(nil), tags...)
+	return &ctx
+}
+
+// DefaultCgoEnabled reports whether go build enables cgo for the target platform when CGO_ENABLED is
+// not set, which is only the case when building for the host with a working C toolchain.
+func DefaultCgoEnabled(goos, goarch string) bool {
+	return goos == runtime.GOOS && goarch == runtime.GOARCH && build.Default.CgoEnabled
+}
+
+// IsMainPackage reports whether the Go files in dir that match the build context form a package main
+// that declares func main.
+func IsMainPackage(ctx *build.Context, dir string) (bool, error) {
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			return false, nil
+		}
+		var multi *build.MultiplePackageError
+		if errors.As(err, &multi) {
+			return false, fmt.Errorf("%s mixes packages %v in files %v", dir, multi.Packages, multi.Files)
+		}
+		return false, err
+	}
+	if pkg.Name != "main" {
+		return false, nil
+	}
+
+	fset := token.NewFileSet()
+	for _, name := range append(pkg.GoFiles, pkg.CgoFiles...) {
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return false, err
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// FindMainPackages returns the directories under root, root included, that hold a main package for the
+// build context. Directories excluded by IsExcludedBinaryDir and testdata are skipped.
+func FindMainPackages(ctx *build.Context, root string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && (IsExcludedBinaryDir(d.Name()) || d.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		isMain, err := IsMainPackage(ctx, path)
+		if err != nil {
+			return err
+		}
+		if isMain {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
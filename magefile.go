@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 
@@ -31,26 +32,43 @@ var (
 
 // Build support specifical binary build.
 //
-// Example: `mage build openim-api openim-rpc-user seq`
-func Build() {
+// Example: `mage build openim-api openim-rpc-user seq` or `mage build --force --keep-going openim-api`
+func Build(ctx context.Context) {
 	flag.Parse()
 	bin := flag.Args()
 	if len(bin) != 0 {
 		bin = bin[1:]
 	}
 
-	mageutil.WithSpinner("Building binaries...", func() {
-		mageutil.Build(bin, nil, nil)
+	bin, buildOpt, err := mageutil.ParseBuildArgs(bin)
+	if err != nil {
+		mageutil.PrintRed(err.Error())
+		os.Exit(1)
+	}
+
+	err = mageutil.WithSpinnerE("Building binaries...", func() error {
+		_, err := mageutil.BuildWithContext(ctx, bin, nil, buildOpt)
+		return err
 	})
+	if err != nil {
+		mageutil.PrintRed("build failed " + err.Error())
+		os.Exit(1)
+	}
 }
 
-func BuildWithCustomConfig() {
+func BuildWithCustomConfig(ctx context.Context) {
 	flag.Parse()
 	bin := flag.Args()
 	if len(bin) != 0 {
 		bin = bin[1:]
 	}
 
+	bin, buildOpt, err := mageutil.ParseBuildArgs(bin)
+	if err != nil {
+		mageutil.PrintRed(err.Error())
+		os.Exit(1)
+	}
+
 	config := &mageutil.PathOptions{
 		RootDir:   &customRootDir,   // default is "."(current directory)
 		OutputDir: &customOutputDir, // default is "_output"
@@ -58,9 +76,14 @@ func BuildWithCustomConfig() {
 		ToolsDir:  &customToolsDir,  // default is "tools"
 	}
 
-	mageutil.WithSpinner("Building binaries with custom config...", func() {
-		mageutil.Build(bin, config, nil)
+	err = mageutil.WithSpinnerE("Building binaries with custom config...", func() error {
+		_, err := mageutil.BuildWithContext(ctx, bin, config, buildOpt)
+		return err
 	})
+	if err != nil {
+		mageutil.PrintRed("build failed " + err.Error())
+		os.Exit(1)
+	}
 }
 
 func Start() {
@@ -77,8 +100,14 @@ func Start() {
 		bin = bin[1:]
 	}
 
+	bin, startOpt, err := mageutil.ParseStartArgs(bin)
+	if err != nil {
+		mageutil.PrintRed(err.Error())
+		os.Exit(1)
+	}
+
 	mageutil.WithSpinner("Starting tools and services...", func() {
-		mageutil.StartToolsAndServices(bin, nil)
+		mageutil.StartToolsAndServicesWithOptions(bin, nil, startOpt)
 	})
 }
 
@@ -96,6 +125,12 @@ func StartWithCustomConfig() {
 		bin = bin[1:]
 	}
 
+	bin, startOpt, err := mageutil.ParseStartArgs(bin)
+	if err != nil {
+		mageutil.PrintRed(err.Error())
+		os.Exit(1)
+	}
+
 	config := &mageutil.PathOptions{
 		RootDir:   &customRootDir,   // default is "."(current directory)
 		OutputDir: &customOutputDir, // default is "_output"
@@ -103,12 +138,23 @@ func StartWithCustomConfig() {
 	}
 
 	mageutil.WithSpinner("Starting tools and services with custom config...", func() {
-		mageutil.StartToolsAndServices(bin, config)
+		mageutil.StartToolsAndServicesWithOptions(bin, config, startOpt)
 	})
 }
 
+// Stop stops all services, or the given ones.
+//
+// Example: `mage stop` or `mage stop @rpc openim-api`
 func Stop() {
-	mageutil.WithSpinner("Checking service status...", mageutil.StopAndCheckBinaries)
+	flag.Parse()
+	bin := flag.Args()
+	if len(bin) != 0 {
+		bin = bin[1:]
+	}
+
+	mageutil.WithSpinner("Checking service status...", func() {
+		mageutil.StopAndCheckSelectedBinaries(bin)
+	})
 }
 
 func Check() {
@@ -119,11 +165,23 @@ func Protocol() {
 	mageutil.WithSpinnerE("Generating protocol artifacts...", mageutil.Protocol)
 }
 
+// Export builds the binaries and archives them with a mage launcher, all binaries or the given ones.
+//
+// Example: `mage export` or `mage export @rpc 'openim-rpc-*'`
 func Export() {
+	flag.Parse()
+	bin := flag.Args()
+	if len(bin) != 0 {
+		bin = bin[1:]
+	}
+
 	exportOpt := &mageutil.ExportOptions{
 		ProjectName: &customExportProjectName,
 		BuildOpt:    customExportBuildOpt,
 	}
+	if len(bin) > 0 {
+		exportOpt.Binaries = &bin
+	}
 	err := mageutil.WithSpinnerE("Exporting launcher archive...", func() error {
 		return mageutil.ExportMageLauncherArchived(nil, exportOpt)
 	})
@@ -132,3 +190,63 @@ func Export() {
 		os.Exit(1)
 	}
 }
+
+// Docker generates Dockerfiles and a docker-compose.yml from the built linux binaries.
+//
+// Example: `PLATFORMS=linux_amd64 mage build && mage docker`
+func Docker() {
+	err := mageutil.WithSpinnerE("Generating docker artifacts...", func() error {
+		return mageutil.GenerateDockerArtifacts(nil)
+	})
+	if err != nil {
+		mageutil.PrintRed("docker failed " + err.Error())
+		os.Exit(1)
+	}
+}
+
+// Race prints the data races found per service by the race detector variant in its latest run.
+//
+// Example: `mage build --race && mage start --race && mage stop && mage race`
+func Race() {
+	if err := mageutil.RaceReport(); err != nil {
+		mageutil.PrintRed("race failed " + err.Error())
+		os.Exit(1)
+	}
+}
+
+// Coverage merges the coverage data of stopped services built with --cover into a text profile
+// and an HTML report.
+//
+// Example: `mage build --cover && mage start && mage stop && mage coverage`
+func Coverage() {
+	err := mageutil.WithSpinnerE("Merging coverage data...", func() error {
+		return mageutil.MergeCoverage()
+	})
+	if err != nil {
+		mageutil.PrintRed("coverage failed " + err.Error())
+		os.Exit(1)
+	}
+}
+
+// Dev builds and starts the binaries, then rebuilds and restarts the affected ones on source change.
+//
+// Example: `mage dev` or `mage dev openim-api openim-rpc-user`
+func Dev() {
+	mageutil.InitForSSC()
+	err := setMaxOpenFiles()
+	if err != nil {
+		mageutil.PrintRed("setMaxOpenFiles failed " + err.Error())
+		os.Exit(1)
+	}
+
+	flag.Parse()
+	bin := flag.Args()
+	if len(bin) != 0 {
+		bin = bin[1:]
+	}
+
+	if err := mageutil.Dev(bin); err != nil {
+		mageutil.PrintRed("dev failed " + err.Error())
+		os.Exit(1)
+	}
+}
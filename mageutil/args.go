@@ -0,0 +1,118 @@
+package mageutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseBuildArgs separates the build flags from the binary names passed to a build target,
+// e.g. `mage build --force -k -j 8 openim-api`. Flags override the corresponding environment variables.
+func ParseBuildArgs(args []string) ([]string, *BuildOptions, error) {
+	opt := &BuildOptions{}
+	var binaries []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			binaries = append(binaries, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		// -j8 is accepted as a shorthand for -j 8.
+		if strings.HasPrefix(name, "j") && len(name) > 1 && !hasValue && isDigits(name[1:]) {
+			name, value, hasValue = "j", name[1:], true
+		}
+		nextValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("build flag %s requires a value", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "force":
+			force := true
+			opt.Force = &force
+		case "reproducible":
+			reproducible := true
+			opt.Reproducible = &reproducible
+		case "verify-repro":
+			verify := true
+			opt.VerifyRepro = &verify
+		case "cover":
+			cover := true
+			opt.Cover = &cover
+		case "race":
+			race := true
+			opt.Race = &race
+		case "restart":
+			restart := true
+			opt.Restart = &restart
+		case "k", "keep-going":
+			keepGoing := true
+			opt.KeepGoing = &keepGoing
+		case "changed-since":
+			ref, err := nextValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			opt.ChangedSince = &ref
+		case "timeout":
+			v, err := nextValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			timeout, err := time.ParseDuration(v)
+			if err != nil || timeout <= 0 {
+				return nil, nil, fmt.Errorf("invalid value %q for build flag %s, expected a positive duration such as 5m", v, arg)
+			}
+			opt.Timeout = &timeout
+		case "j", "jobs":
+			v, err := nextValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			jobs, err := strconv.Atoi(v)
+			if err != nil || jobs < 1 {
+				return nil, nil, fmt.Errorf("invalid value %q for build flag %s, expected a positive number", v, arg)
+			}
+			opt.Jobs = &jobs
+		default:
+			return nil, nil, fmt.Errorf("unknown build flag %s", arg)
+		}
+	}
+	return binaries, opt, nil
+}
+
+func isDigits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// ParseStartArgs separates the start flags from the binary names passed to a start target,
+// e.g. `mage start --race openim-api`. Flags override the corresponding environment variables.
+func ParseStartArgs(args []string) ([]string, *StartOptions, error) {
+	opt := &StartOptions{}
+	var binaries []string
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			binaries = append(binaries, arg)
+			continue
+		}
+		switch strings.TrimLeft(arg, "-") {
+		case "race":
+			race := true
+			opt.Race = &race
+		default:
+			return nil, nil, fmt.Errorf("unknown start flag %s", arg)
+		}
+	}
+	return binaries, opt, nil
+}
@@ -0,0 +1,45 @@
+package mageutil
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseBuildArgsJobs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		jobs     int
+		binaries []string
+		wantErr  bool
+	}{
+		{args: []string{"-j8"}, jobs: 8},
+		{args: []string{"-j", "8"}, jobs: 8},
+		{args: []string{"-j=8"}, jobs: 8},
+		{args: []string{"--jobs", "8"}, jobs: 8},
+		{args: []string{"--jobs=8"}, jobs: 8},
+		{args: []string{"--jobs", "8", "openim-api"}, jobs: 8, binaries: []string{"openim-api"}},
+		{args: []string{"-j0"}, wantErr: true},
+		{args: []string{"--jobs", "x"}, wantErr: true},
+		{args: []string{"--jobs"}, wantErr: true},
+		{args: []string{"-jx"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		binaries, opt, err := ParseBuildArgs(tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseBuildArgs(%q) succeeded, want an error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseBuildArgs(%q) failed: %v", tt.args, err)
+			continue
+		}
+		if got := opt.GetJobs(); got != tt.jobs {
+			t.Errorf("ParseBuildArgs(%q) jobs = %d, want %d", tt.args, got, tt.jobs)
+		}
+		if !slices.Equal(binaries, tt.binaries) {
+			t.Errorf("ParseBuildArgs(%q) binaries = %q, want %q", tt.args, binaries, tt.binaries)
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package mageutil
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/openimsdk/gomake/internal/util"
@@ -30,21 +35,64 @@ func CheckAndReportBinariesStatus() {
 }
 
 func StopAndCheckBinaries() {
+	StopAndCheckSelectedBinaries(nil)
+}
+
+// StopAndCheckSelectedBinaries stops the services selected by names, globs and @groups, all
+// configured services when binaries is empty.
+func StopAndCheckSelectedBinaries(binaries []string) {
 	InitForSSC()
-	KillExistBinaries()
-	err := attemptCheckBinaries()
-	if err != nil {
+	services := configuredServiceNames()
+	if len(binaries) > 0 {
+		var err error
+		if services, err = selectServices(binaries); err != nil {
+			PrintRed(err.Error())
+			return
+		}
+		PrintBlue(fmt.Sprintf("Stopping services: %v", services))
+	}
+	if err := stopServices(services); err != nil {
 		PrintRed(err.Error())
 		return
 	}
-	PrintGreen("All services have been stopped")
+	if len(binaries) > 0 {
+		PrintGreen(fmt.Sprintf("Services have been stopped: %v", services))
+	} else {
+		PrintGreen("All services have been stopped")
+	}
+	if summaries, err := CollectRaceReports(); err == nil && len(summaries) > 0 {
+		if err := RaceReport(); err != nil {
+			PrintRed(err.Error())
+		}
+	}
+	if err := RunHooks(HookPostStop, services); err != nil {
+		PrintRed(err.Error())
+	}
+}
+
+// stopServices runs the preStop hooks of services, kills their instances, including the race detector
+// variant, and waits for them to exit.
+func stopServices(services []string) error {
+	if err := RunHooks(HookPreStop, services); err != nil {
+		return err
+	}
+	killServiceBinaries(services)
+	return attemptCheckBinaries(services)
+}
+
+func configuredServiceNames() []string {
+	names := make([]string, 0, len(serviceBinaries))
+	for binary := range serviceBinaries {
+		names = append(names, binary)
+	}
+	return names
 }
 
-func attemptCheckBinaries() error {
+func attemptCheckBinaries(services []string) error {
 	const maxAttempts = 15
 	var err error
 	for i := 0; i < maxAttempts; i++ {
-		err = CheckBinariesStop()
+		err = checkServicesStop(services)
 		if err == nil {
 			return nil
 		}
@@ -57,7 +105,37 @@ func attemptCheckBinaries() error {
 	return fmt.Errorf("already waited for %d seconds, some services have still not stopped", maxAttempts)
 }
 
+type StartOptions struct {
+	Race *bool // Start the race detector variant built with --race
+}
+
+func (opt *StartOptions) GetRace() bool {
+	return util.NilAsZero(util.NilAsZero(opt).Race)
+}
+
+func ResolveStartOptions(codeOpt *StartOptions, envOpt *StartOptions) *StartOptions {
+	fromCode := StartOptions{}
+	if codeOpt != nil {
+		fromCode = *codeOpt
+	}
+
+	fromEnv := StartOptions{}
+	if envOpt != nil {
+		fromEnv = *envOpt
+	}
+
+	return &StartOptions{
+		Race: util.CoalescePtr(fromCode.Race, fromEnv.Race),
+	}
+}
+
 func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
+	StartToolsAndServicesWithOptions(binaries, pathOpts, nil)
+}
+
+// StartToolsAndServicesWithOptions starts the tools and then the services, from the race detector
+// output tree when the race option is set.
+func StartToolsAndServicesWithOptions(binaries []string, pathOpts *PathOptions, startOpt *StartOptions) {
 	if pathOpts != nil {
 		if err := UpdateGlobalPaths(pathOpts); err != nil {
 			PrintRed("Failed to update paths: " + err.Error())
@@ -65,6 +143,30 @@ func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
 		}
 	}
 
+	startOpt = ResolveStartOptions(startOpt, &StartOptions{
+		Race: util.ResolveEnvOption[bool]("RACE"),
+	})
+	if startOpt.GetRace() {
+		restore, err := useRaceVariant()
+		if err != nil {
+			PrintRed(err.Error())
+			os.Exit(1)
+		}
+		defer restore()
+		PrintBlue(fmt.Sprintf("Starting the race detector variant from %s", Paths.OutputHostBin))
+	}
+
+	if len(binaries) > 0 {
+		var err error
+		binaries, err = expandBinaryNames(binaries, configuredBinaryNames(), func(name string) bool {
+			return isExecutableFile(GetBinFullPath(name)) || isExecutableFile(GetBinToolsFullPath(name))
+		})
+		if err != nil {
+			PrintRed(err.Error())
+			return
+		}
+	}
+
 	if len(binaries) > 0 {
 		PrintBlue(fmt.Sprintf("Starting specified binaries: %v", binaries))
 
@@ -93,6 +195,12 @@ func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
 		PrintBlue(fmt.Sprintf("Cmd binaries to start: %v", cmdBinaries))
 		PrintBlue(fmt.Sprintf("Tools binaries to start: %v", toolsBinaries))
 
+		hookBinaries := append(slices.Clone(toolsBinaries), cmdBinaries...)
+		if err := RunHooks(HookPreStart, hookBinaries); err != nil {
+			PrintRed(err.Error())
+			return
+		}
+
 		if len(toolsBinaries) > 0 {
 			PrintBlue("Starting specified tools...")
 			if err := StartTools(toolsBinaries...); err != nil {
@@ -105,7 +213,7 @@ func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
 
 		if len(cmdBinaries) > 0 {
 			KillExistBinaries()
-			err := attemptCheckBinaries()
+			err := attemptCheckBinaries(configuredServiceNames())
 			if err != nil {
 				PrintRed("Some services running, details are as follows, abort start " + err.Error())
 				return
@@ -118,6 +226,15 @@ func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
 			}
 			CheckAndReportBinariesStatus()
 		}
+		if err := RunHooks(HookPostStart, hookBinaries); err != nil {
+			PrintRed(err.Error())
+		}
+		return
+	}
+
+	hookBinaries := append(slices.Clone(toolBinaries), configuredServiceNames()...)
+	if err := RunHooks(HookPreStart, hookBinaries); err != nil {
+		PrintRed(err.Error())
 		return
 	}
 
@@ -130,7 +247,7 @@ func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
 	PrintGreen("All tools executed successfully")
 
 	KillExistBinaries()
-	err := attemptCheckBinaries()
+	err := attemptCheckBinaries(configuredServiceNames())
 	if err != nil {
 		PrintRed("Some services running, details are as follows, abort start " + err.Error())
 		return
@@ -142,6 +259,9 @@ func StartToolsAndServices(binaries []string, pathOpts *PathOptions) {
 		return
 	}
 	CheckAndReportBinariesStatus()
+	if err := RunHooks(HookPostStart, hookBinaries); err != nil {
+		PrintRed(err.Error())
+	}
 }
 
 func isExecutableFile(filePath string) bool {
@@ -165,13 +285,80 @@ func isExecutableFile(filePath string) bool {
 	return info.Mode()&0111 != 0
 }
 
-func Build(binaries []string, pathOpts *PathOptions, buildOpt *BuildOptions) {
-	resolvedBuildOpt := ResolveBuildOptions(buildOpt, &BuildOptions{
-		CgoEnabled: util.ResolveEnvOption[string]("CGO_ENABLED"),
-		Release:    util.ResolveEnvOption[bool]("RELEASE"),
-		Compress:   util.ResolveEnvOption[bool]("COMPRESS"),
-		Platforms:  util.ResolveEnvOption[[]string]("PLATFORMS"),
+// compileAll compiles the binaries for every platform in one build session and writes the checksums
+// of the output directories. It runs no hooks and writes no reports. postBuild runs the post-build
+// pipeline on every compiled binary.
+func compileAll(ctx context.Context, binaries, platforms []string, buildOpt *BuildOptions, postBuild bool) (BuildResults, error) {
+	session, err := newBuildSession(buildOpt)
+	if err != nil {
+		return nil, err
+	}
+	defer session.cleanup()
+	session.postBuild = postBuild
+
+	// Platforms are compiled concurrently, the session's job slots bound the total parallelism.
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results BuildResults
+	)
+	for _, platform := range platforms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			platformResults := compileForPlatform(ctx, session, platform, binaries)
+			mu.Lock()
+			results = append(results, platformResults...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	session.saveManifest()
+	if err := writeBuildChecksums(results); err != nil {
+		PrintYellow(fmt.Sprintf("Failed to write checksums: %v", err))
+	}
+	return results, nil
+}
+
+// resolveBuildOptionsWithEnv layers the build options given in code over those from the environment.
+func resolveBuildOptionsWithEnv(buildOpt *BuildOptions) *BuildOptions {
+	return ResolveBuildOptions(buildOpt, &BuildOptions{
+		CgoEnabled:     util.ResolveEnvOption[string]("CGO_ENABLED"),
+		Release:        util.ResolveEnvOption[bool]("RELEASE"),
+		Compress:       util.ResolveEnvOption[bool]("COMPRESS"),
+		Platforms:      util.ResolveEnvOption[[]string]("PLATFORMS"),
+		Force:          util.ResolveEnvOption[bool]("FORCE"),
+		VersionStamp:   util.ResolveEnvOption[bool]("VERSION_STAMP"),
+		VersionPackage: util.ResolveEnvOption[string]("VERSION_PACKAGE"),
+		Tags:           util.ResolveEnvOption[[]string]("BUILD_TAGS"),
+		GCFlags:        util.ResolveEnvOption[string]("GOMAKE_GCFLAGS"),
+		LDFlags:        util.ResolveEnvOption[string]("GOMAKE_LDFLAGS"),
+		Jobs:           util.ResolveEnvOption[int]("GOMAKE_JOBS"),
+		KeepGoing:      util.ResolveEnvOption[bool]("KEEP_GOING"),
+		Reproducible:   util.ResolveEnvOption[bool]("REPRODUCIBLE"),
+		VerifyRepro:    util.ResolveEnvOption[bool]("VERIFY_REPRO"),
+		Cover:          util.ResolveEnvOption[bool]("COVER"),
+		CoverPkg:       util.ResolveEnvOption[string]("COVER_PKG"),
+		Race:           util.ResolveEnvOption[bool]("RACE"),
+		ChangedSince:   util.ResolveEnvOption[string]("CHANGED_SINCE"),
+		Timeout:        util.ResolveEnvOption[time.Duration]("BUILD_TIMEOUT"),
+		Restart:        util.ResolveEnvOption[bool]("RESTART"),
 	})
+}
+
+// Build compiles the binaries for every configured platform and returns a result per binary and
+// platform. The returned error joins the errors of all binaries that failed to build.
+func Build(binaries []string, pathOpts *PathOptions, buildOpt *BuildOptions) (BuildResults, error) {
+	return BuildWithContext(context.Background(), binaries, pathOpts, buildOpt)
+}
+
+// BuildWithContext is Build stopped by cancelling ctx or by an interrupt signal. Running compilations
+// are terminated, their outputs removed and the binaries reported as cancelled.
+func BuildWithContext(ctx context.Context, binaries []string, pathOpts *PathOptions, buildOpt *BuildOptions) (BuildResults, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	startedAt := time.Now()
+	resolvedBuildOpt := resolveBuildOptionsWithEnv(buildOpt)
 
 	if _, err := os.Stat(StartConfigFile); err == nil {
 		InitForSSC()
@@ -179,21 +366,112 @@ func Build(binaries []string, pathOpts *PathOptions, buildOpt *BuildOptions) {
 
 	if pathOpts != nil {
 		if err := UpdateGlobalPaths(pathOpts); err != nil {
-			PrintRed("Failed to update paths: " + err.Error())
-			os.Exit(1)
+			return nil, fmt.Errorf("failed to update paths: %w", err)
+		}
+	}
+
+	// Platforms are validated before any work starts, shorthands such as linux/all are expanded.
+	platforms, err := ResolvePlatforms(resolvedBuildOpt.GetPlatforms())
+	if err != nil {
+		return nil, err
+	}
+	resolvedBuildOpt.Platforms = &platforms
+
+	if resolvedBuildOpt.GetRace() {
+		restore, err := useRaceVariant()
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+		PrintBlue(fmt.Sprintf("Building the race detector variant into %s", Paths.Output))
+	}
+
+	if resolvedBuildOpt.GetVerifyRepro() {
+		results, err := verifyReproducibleBuild(ctx, binaries, resolvedBuildOpt)
+		if reportErr := WriteBuildReports(results, startedAt); reportErr != nil {
+			PrintYellow(reportErr.Error())
 		}
+		return results, err
 	}
 
-	compileBinaries := getBinaries(binaries)
+	if err := validatePostBuildSteps(); err != nil {
+		return nil, err
+	}
+
+	compileBinaries, err := getBinaries(binaries, resolvedBuildOpt)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOutputNameCollisions(compileBinaries); err != nil {
+		return nil, err
+	}
 	if cgoEnabled := resolvedBuildOpt.GetCgoEnabled(); cgoEnabled != "" {
 		PrintBlue(fmt.Sprintf("CGO_ENABLED %s", cgoEnabled))
 	}
-	platforms := resolvedBuildOpt.GetPlatforms()
-	if len(platforms) == 0 {
-		platforms = []string{DetectPlatform()}
+	if ref := resolvedBuildOpt.GetChangedSince(); ref != "" {
+		selected, err := selectChangedBinaries(compileBinaries, ref, platforms, resolvedBuildOpt)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) == 0 {
+			PrintGreen(fmt.Sprintf("No binaries are affected by changes since %s", ref))
+			if err := WriteBuildReports(nil, startedAt); err != nil {
+				PrintYellow(err.Error())
+			}
+			return nil, nil
+		}
+		PrintBlue(fmt.Sprintf("Building %d of %d binaries affected by changes since %s", len(selected), len(compileBinaries), ref))
+		compileBinaries = selected
 	}
-	for _, platform := range platforms {
-		CompileForPlatform(resolvedBuildOpt, platform, compileBinaries)
+
+	hookBinaries := make([]string, 0, len(compileBinaries))
+	for _, binary := range compileBinaries {
+		hookBinaries = append(hookBinaries, binaryOutputName(binary))
+	}
+	if err := RunHooks(HookPreBuild, hookBinaries); err != nil {
+		return nil, err
+	}
+	// The binaries of running services are hashed before they are replaced.
+	var running map[string][]string
+	if resolvedBuildOpt.GetRestart() {
+		if running, err = runningServiceHashes(); err != nil {
+			return nil, fmt.Errorf("failed to find running services: %w", err)
+		}
+		if len(running) == 0 {
+			PrintBlue("No services are running, nothing will be restarted")
+		}
+	}
+	results, err := compileAll(ctx, compileBinaries, platforms, resolvedBuildOpt, true)
+	if err != nil {
+		return nil, err
+	}
+	createStartConfigYML(results)
+	sbomErr := writeBuildSBOMs(results)
+	if sbomErr != nil {
+		PrintRed(fmt.Sprintf("Failed to write SBOMs: %v", sbomErr))
+	}
+	PrintBuildSummary(results)
+	if err := WriteBuildReports(results, startedAt); err != nil {
+		PrintYellow(err.Error())
+	}
+
+	if ctx.Err() != nil {
+		return results, fmt.Errorf("%d of %d builds cancelled", len(results.Cancelled()), len(results))
+	}
+	if err := results.Err(); err != nil {
+		return results, fmt.Errorf("%d of %d builds failed: %w", len(results.Failed()), len(results), err)
+	}
+	if sbomErr != nil {
+		return results, fmt.Errorf("failed to write SBOMs: %w", sbomErr)
 	}
 	PrintGreen("All specified binaries under cmd and tools were successfully compiled.")
+	if err := RunHooks(HookPostBuild, hookBinaries); err != nil {
+		return results, err
+	}
+	if len(running) > 0 {
+		if err := restartChangedServices(results, running); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
 }
@@ -1,12 +1,21 @@
 package mageutil
 
 import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"errors"
 	"fmt"
+	"go/build"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/openimsdk/gomake/internal/util"
 )
@@ -16,8 +25,52 @@ type BuildOptions struct {
 	Release    *bool
 	Compress   *bool
 	Platforms  *[]string
+	Force      *bool // Rebuild binaries even if the build manifest says they are up to date
+
+	VersionStamp   *bool   // Stamp git version metadata into the binaries with -ldflags -X
+	VersionPackage *string // Package whose version variables are stamped, default is "main"
+
+	Tags    *[]string // Build tags passed with -tags
+	GCFlags *string   // Flags passed with -gcflags
+	LDFlags *string   // Extra flags appended to -ldflags
+
+	Jobs      *int  // Number of concurrent compilations across all platforms, default is the number of CPUs
+	KeepGoing *bool // Keep compiling the remaining binaries after a failure
+
+	Reproducible *bool // Pin the build ID and VCS stamp and run the compiler with an allowlisted environment
+	VerifyRepro  *bool // Build twice into separate directories and fail if any binary differs
+
+	Cover    *bool   // Build coverage-instrumented binaries with -cover
+	CoverPkg *string // Package patterns passed with -coverpkg, default is the main module
+	Race     *bool   // Build services with -race into the separate race output tree, forces cgo on
+
+	ChangedSince *string // Git ref, only binaries depending on files changed since it are built
+
+	Timeout *time.Duration // Limit of the compilation and post-build steps of each binary, zero means no limit
+	Restart *bool          // Restart the running services whose binary changed after a successful build
 }
 
+// BinaryBuildOptions are the per-binary overrides declared in the build section of start-config.yml.
+type BinaryBuildOptions struct {
+	CgoEnabled *string        `yaml:"cgoEnabled"`
+	Release    *bool          `yaml:"release"`
+	Compress   *bool          `yaml:"compress"`
+	Tags       []string       `yaml:"tags"`    // Added to the global build tags
+	GCFlags    *string        `yaml:"gcflags"` // Replaces the global gcflags
+	LDFlags    *string        `yaml:"ldflags"` // Appended to the global ldflags
+	Timeout    *time.Duration `yaml:"timeout"` // Replaces the global build timeout
+}
+
+type BuildConfig struct {
+	Binaries  map[string]*BinaryBuildOptions `yaml:"binaries"`  // Keyed by binary name
+	Naming    NamingRule                     `yaml:"naming"`    // How output names are derived from source paths, default is NamingBase
+	Names     map[string]string              `yaml:"names"`     // Explicit output names keyed by root-relative source path, override the naming rule
+	Platforms map[string]*PlatformToolchain  `yaml:"platforms"` // C toolchains for cgo keyed by platform, such as linux_arm64
+	PostBuild []PostBuildStepConfig          `yaml:"postBuild"` // Steps run on every built binary, in order
+}
+
+var buildConfig BuildConfig
+
 func (opt *BuildOptions) GetCgoEnabled() string {
 	return util.NilAsZero(util.NilAsZero(opt).CgoEnabled)
 }
@@ -34,222 +87,637 @@ func (opt *BuildOptions) GetPlatforms() []string {
 	return util.NilAsZero(util.NilAsZero(opt).Platforms)
 }
 
-func CompileForPlatform(buildOpt *BuildOptions, platform string, compileBinaries []string) {
-	var cmdBinaries, toolsBinaries []string
+func (opt *BuildOptions) GetForce() bool {
+	return util.NilAsZero(util.NilAsZero(opt).Force)
+}
 
-	toolsPrefix := Paths.ToolsDir
-	cmdPrefix := Paths.SrcDir
+func (opt *BuildOptions) GetVersionStamp() bool {
+	return util.NilAsZero(util.NilAsZero(opt).VersionStamp)
+}
 
-	if Paths.SrcDir == "." {
-		cmdPrefix = ""
+func (opt *BuildOptions) GetVersionPackage() string {
+	pkg := strings.TrimSpace(util.NilAsZero(util.NilAsZero(opt).VersionPackage))
+	if pkg == "" {
+		return DefaultVersionPackage
 	}
+	return pkg
+}
 
-	if toolsPrefix != "" {
-		toolsPrefix += string(filepath.Separator)
-	}
-	if cmdPrefix != "" {
-		cmdPrefix += string(filepath.Separator)
+func (opt *BuildOptions) GetTags() []string {
+	return util.NilAsZero(util.NilAsZero(opt).Tags)
+}
+
+func (opt *BuildOptions) GetGCFlags() string {
+	return util.NilAsZero(util.NilAsZero(opt).GCFlags)
+}
+
+func (opt *BuildOptions) GetLDFlags() string {
+	return util.NilAsZero(util.NilAsZero(opt).LDFlags)
+}
+
+func (opt *BuildOptions) GetJobs() int {
+	jobs := util.NilAsZero(util.NilAsZero(opt).Jobs)
+	if jobs <= 0 {
+		return runtime.NumCPU()
 	}
+	return jobs
+}
 
-	for _, binary := range compileBinaries {
-		if toolsPrefix != "" && strings.HasPrefix(binary, toolsPrefix) {
-			toolsBinary := strings.TrimPrefix(binary, toolsPrefix)
-			toolsBinaries = append(toolsBinaries, toolsBinary)
-		} else if cmdPrefix == "" || strings.HasPrefix(binary, cmdPrefix) {
-			var cmdBinary string
-			if cmdPrefix == "" {
-				cmdBinary = binary
-			} else {
-				cmdBinary = strings.TrimPrefix(binary, cmdPrefix)
+func (opt *BuildOptions) GetKeepGoing() bool {
+	return util.NilAsZero(util.NilAsZero(opt).KeepGoing)
+}
+
+func (opt *BuildOptions) GetReproducible() bool {
+	return util.NilAsZero(util.NilAsZero(opt).Reproducible)
+}
+
+func (opt *BuildOptions) GetVerifyRepro() bool {
+	return util.NilAsZero(util.NilAsZero(opt).VerifyRepro)
+}
+
+func (opt *BuildOptions) GetCover() bool {
+	return util.NilAsZero(util.NilAsZero(opt).Cover)
+}
+
+func (opt *BuildOptions) GetRace() bool {
+	return util.NilAsZero(util.NilAsZero(opt).Race)
+}
+
+func (opt *BuildOptions) GetChangedSince() string {
+	return strings.TrimSpace(util.NilAsZero(util.NilAsZero(opt).ChangedSince))
+}
+
+func (opt *BuildOptions) GetTimeout() time.Duration {
+	return util.NilAsZero(util.NilAsZero(opt).Timeout)
+}
+
+func (opt *BuildOptions) GetRestart() bool {
+	return util.NilAsZero(util.NilAsZero(opt).Restart)
+}
+
+func (opt *BuildOptions) GetCoverPkg() string {
+	return strings.TrimSpace(util.NilAsZero(util.NilAsZero(opt).CoverPkg))
+}
+
+// ForBinary layers the overrides configured for the binary on top of the options.
+func (opt *BuildOptions) ForBinary(name string) *BuildOptions {
+	resolved := util.NilAsZero(opt)
+	override := buildConfig.Binaries[strings.TrimSuffix(name, ".exe")]
+	if override == nil {
+		return &resolved
+	}
+
+	resolved.CgoEnabled = util.CoalescePtr(override.CgoEnabled, resolved.CgoEnabled)
+	resolved.Release = util.CoalescePtr(override.Release, resolved.Release)
+	resolved.Compress = util.CoalescePtr(override.Compress, resolved.Compress)
+	resolved.GCFlags = util.CoalescePtr(override.GCFlags, resolved.GCFlags)
+	resolved.Timeout = util.CoalescePtr(override.Timeout, resolved.Timeout)
+	if len(override.Tags) > 0 {
+		tags := slices.Clone(resolved.GetTags())
+		for _, tag := range override.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
 			}
-			cmdBinaries = append(cmdBinaries, cmdBinary)
-		} else {
-			PrintYellow(fmt.Sprintf("Binary %s does not have a valid prefix. Skipping...", binary))
 		}
+		resolved.Tags = &tags
 	}
+	if override.LDFlags != nil {
+		ldflags := strings.TrimSpace(resolved.GetLDFlags() + " " + *override.LDFlags)
+		resolved.LDFlags = &ldflags
+	}
+	return &resolved
+}
 
-	PrintBlue(fmt.Sprintf("Cmd binaries: %v", cmdBinaries))
-	PrintBlue(fmt.Sprintf("Tools binaries: %v", toolsBinaries))
+// buildSession holds the state shared by all compilations of one build.
+type buildSession struct {
+	opt       *BuildOptions
+	manifest  *BuildManifest
+	goVersion string
+	version   *VersionInfo  // Nil unless version stamping is enabled
+	jobs      chan struct{} // Compilation slots shared by all platforms
+	failed    atomic.Bool   // Set on the first failure, stops scheduling new compilations unless keep-going is on
+	tmpDir    string        // GOTMPDIR of the compilations, holds the work directories interrupted builds leave behind
+	postBuild bool          // Whether the post-build pipeline runs on the compiled binaries
+}
 
-	var cmdCompiledDirs []string
-	var toolsCompiledDirs []string
+func newBuildSession(buildOpt *BuildOptions) (*buildSession, error) {
+	goVersion, err := resolveGoVersion()
+	if err != nil {
+		PrintYellow(fmt.Sprintf("%v, incremental build disabled", err))
+	}
+	jobs := buildOpt.GetJobs()
+	PrintGreen(fmt.Sprintf("The number of concurrent compilations is %d", jobs))
+	tmpDir, err := os.MkdirTemp(os.Getenv("GOTMPDIR"), "gomake-build-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary build directory: %v", err)
+	}
+	session := &buildSession{
+		opt:       buildOpt,
+		manifest:  LoadBuildManifest(),
+		goVersion: goVersion,
+		jobs:      make(chan struct{}, jobs),
+		tmpDir:    tmpDir,
+	}
 
-	if len(cmdBinaries) > 0 {
-		PrintBlue(fmt.Sprintf("Compiling cmd binaries for %s...", platform))
-		cmdCompiledDirs = compileDir(buildOpt, filepath.Join(Paths.Root, Paths.SrcDir), Paths.OutputBinPath, platform, cmdBinaries)
+	if ws := currentWorkspace(); ws != nil {
+		PrintBlue(fmt.Sprintf("Using workspace %s", ws.File))
+		for _, mod := range ws.Modules {
+			PrintBlue(fmt.Sprintf("  %s => %s", mod.Path, mod.Dir))
+		}
 	}
 
-	if len(toolsBinaries) > 0 {
-		PrintBlue(fmt.Sprintf("Compiling tools binaries for %s...", platform))
-		toolsCompiledDirs = compileDir(buildOpt, filepath.Join(Paths.Root, Paths.ToolsDir), Paths.OutputBinToolPath, platform, toolsBinaries)
+	if buildOpt.GetVersionStamp() {
+		session.version, err = ResolveVersionInfo(Paths.Root)
+		if err != nil {
+			session.cleanup()
+			return nil, fmt.Errorf("failed to resolve version metadata: %w", err)
+		}
+		PrintBlue(fmt.Sprintf("Stamping version %s (commit %s) into %s", session.version.Label(), session.version.Commit, buildOpt.GetVersionPackage()))
 	}
+	return session, nil
+}
 
-	createStartConfigYML(cmdCompiledDirs, toolsCompiledDirs)
+func (s *buildSession) acquireJob() {
+	s.jobs <- struct{}{}
+}
+
+func (s *buildSession) releaseJob() {
+	<-s.jobs
+}
+
+// aborted reports whether compilations should no longer be started because an earlier one failed.
+func (s *buildSession) aborted() bool {
+	return s.failed.Load() && !s.opt.GetKeepGoing()
+}
+
+// goBuildFlags returns the flags passed to go build, and the same flags without volatile values
+// such as the build time, which are recorded in the build manifest.
+func (s *buildSession) goBuildFlags(binOpt *BuildOptions) (flags, stableFlags []string) {
+	var ldflags []string
+	reproducible := s.opt.GetReproducible()
+	if binOpt.GetRelease() || reproducible {
+		flags = append(flags, "-trimpath")
+	}
+	if binOpt.GetRelease() {
+		ldflags = append(ldflags, "-s", "-w")
+	}
+	if reproducible {
+		flags = append(flags, "-buildvcs=false")
+		ldflags = append(ldflags, "-buildid=")
+	}
+	if binOpt.GetRace() {
+		flags = append(flags, "-race")
+	}
+	if binOpt.GetCover() {
+		flags = append(flags, "-cover")
+		if pkg := binOpt.GetCoverPkg(); pkg != "" {
+			flags = append(flags, "-coverpkg", pkg)
+		}
+	}
+	if tags := binOpt.GetTags(); len(tags) > 0 {
+		flags = append(flags, "-tags", strings.Join(tags, ","))
+	}
+	if gcflags := binOpt.GetGCFlags(); gcflags != "" {
+		flags = append(flags, "-gcflags", gcflags)
+	}
+	if extra := binOpt.GetLDFlags(); extra != "" {
+		ldflags = append(ldflags, extra)
+	}
+	stableFlags = slices.Clone(flags)
+	stableLDFlags := slices.Clone(ldflags)
+
+	if s.version != nil {
+		pkg := s.opt.GetVersionPackage()
+		// The build time would make every reproducible build differ.
+		ldflags = append(ldflags, s.version.LDFlags(pkg, !reproducible)...)
+		stableLDFlags = append(stableLDFlags, s.version.LDFlags(pkg, false)...)
+	}
+
+	if len(ldflags) > 0 {
+		flags = append(flags, "-ldflags", strings.Join(ldflags, " "))
+		stableFlags = append(stableFlags, "-ldflags", strings.Join(stableLDFlags, " "))
+	}
+	return flags, stableFlags
 }
 
-func compileDir(buildOpt *BuildOptions, sourceDir, outputBase, platform string, compileBinaries []string) []string {
-	releaseEnabled := buildOpt.GetRelease()
-	compressEnabled := buildOpt.GetCompress()
-	cgoEnabled := buildOpt.GetCgoEnabled()
+// cleanup removes the temporary files of the compilations.
+func (s *buildSession) cleanup() {
+	if err := os.RemoveAll(s.tmpDir); err != nil {
+		PrintYellow(fmt.Sprintf("Failed to remove temporary build directory %s: %v", s.tmpDir, err))
+	}
+}
+
+func (s *buildSession) saveManifest() {
+	if err := s.manifest.Save(); err != nil {
+		PrintYellow(err.Error())
+	}
+}
+
+// CompileForPlatform compiles the binaries for one platform. The returned error joins the errors of
+// all binaries that failed to build. Cancelling ctx stops the compilations.
+func CompileForPlatform(ctx context.Context, buildOpt *BuildOptions, platform string, compileBinaries []string) (BuildResults, error) {
+	session, err := newBuildSession(buildOpt)
+	if err != nil {
+		return nil, err
+	}
+	defer session.cleanup()
+	results := compileForPlatform(ctx, session, platform, compileBinaries)
+	session.saveManifest()
+	createStartConfigYML(results)
+	PrintBuildSummary(results)
+	return results, results.Err()
+}
+
+// compileForPlatform compiles the cmd and tools binaries for one platform.
+func compileForPlatform(ctx context.Context, session *buildSession, platform string, compileBinaries []string) BuildResults {
+	// Binaries are grouped by the cmd or tools directory of their module, which is the root module
+	// outside of a go.work workspace.
+	type sourceGroup struct {
+		sourceDir string // Root-relative cmd or tools directory
+		tool      bool
+	}
+	var groups []sourceGroup
+	groupBinaries := make(map[sourceGroup][]string)
+
+	toolsPrefix := normalizedSourcePrefix(Paths.ToolsDir)
+	cmdPrefix := normalizedSourcePrefix(Paths.SrcDir)
+	checkCgoToolchain(platform, session.opt.GetCgoEnabled())
+
+	for _, binary := range compileBinaries {
+		modDir := binaryModuleDir(binary)
+		relPath := moduleRelPath(binary)
+
+		var group sourceGroup
+		var groupBinary string
+		if toolsPrefix != "" && isSubPath(toolsPrefix, relPath) {
+			group = sourceGroup{sourceDir: filepath.Join(modDir, toolsPrefix), tool: true}
+			groupBinary, _ = filepath.Rel(toolsPrefix, relPath)
+		} else if cmdPrefix == "" || isSubPath(cmdPrefix, relPath) {
+			group = sourceGroup{sourceDir: filepath.Join(modDir, cmdPrefix)}
+			groupBinary = relPath
+			if cmdPrefix != "" {
+				groupBinary, _ = filepath.Rel(cmdPrefix, relPath)
+			}
+		} else {
+			PrintYellow(fmt.Sprintf("Binary %s does not have a valid prefix. Skipping...", binary))
+			continue
+		}
+		if _, ok := groupBinaries[group]; !ok {
+			groups = append(groups, group)
+		}
+		groupBinaries[group] = append(groupBinaries[group], groupBinary)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results BuildResults
+	)
+	for _, group := range groups {
+		kind, outputBase := "cmd", Paths.OutputBinPath
+		if group.tool {
+			kind, outputBase = "tools", Paths.OutputBinToolPath
+		}
+		binaries := groupBinaries[group]
+		PrintBlue(fmt.Sprintf("Compiling %s binaries in %s for %s: %v", kind, group.sourceDir, platform, binaries))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			groupResults := compileDir(ctx, session, filepath.Join(Paths.Root, group.sourceDir), outputBase, platform, binaries)
+			for _, r := range groupResults {
+				r.Tool = group.tool
+			}
+			mu.Lock()
+			results = append(results, groupResults...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
 
-	PrintBlue(fmt.Sprintf("Build flags: RELEASE=%t, COMPRESS=%t", releaseEnabled, compressEnabled))
+// compileDir compiles the binaries under sourceDir concurrently, limited by the session's job slots,
+// and returns their results in input order. Binaries not compiled when ctx is done are cancelled.
+func compileDir(ctx context.Context, session *buildSession, sourceDir, outputBase, platform string, compileBinaries []string) BuildResults {
+	buildOpt := session.opt
+
+	PrintBlue(fmt.Sprintf("Build flags: RELEASE=%t, COMPRESS=%t, FORCE=%t", buildOpt.GetRelease(), buildOpt.GetCompress(), buildOpt.GetForce()))
+
+	// failAll reports a problem with the directory itself as a failure of every binary in it.
+	failAll := func(err error) BuildResults {
+		session.failed.Store(true)
+		results := make(BuildResults, 0, len(compileBinaries))
+		for _, binary := range compileBinaries {
+			results = append(results, &BuildResult{
+				Binary:   binarySourcePath(filepath.Join(sourceDir, binary)),
+				Name:     binaryFileName(binaryOutputName(binarySourcePath(filepath.Join(sourceDir, binary))), platform),
+				Platform: platform,
+				Status:   BuildFailed,
+				Err:      err,
+			})
+		}
+		return results
+	}
 
 	if info, err := os.Stat(sourceDir); err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
-		fmt.Printf("Failed read directory %s: %v\n", sourceDir, err)
-		os.Exit(1)
+		return failAll(fmt.Errorf("failed to read directory %s: %v", sourceDir, err))
 	} else if !info.IsDir() {
-		fmt.Printf("Failed %s is not dir\n", sourceDir)
-		os.Exit(1)
+		return failAll(fmt.Errorf("%s is not a directory", sourceDir))
 	}
 
-	targetOS, targetArch := strings.Split(platform, "_")[0], strings.Split(platform, "_")[1]
-	outputDir := filepath.Join(outputBase, targetOS, targetArch)
+	outputDir := filepath.Join(outputBase, platformDir(platform))
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		fmt.Printf("Failed to create directory %s: %v\n", outputDir, err)
-		os.Exit(1)
+		return failAll(fmt.Errorf("failed to create directory %s: %v", outputDir, err))
 	}
 
-	cpuNum := runtime.GOMAXPROCS(0)
-	if cpuNum <= 0 {
-		cpuNum = runtime.NumCPU()
-	} else if cpuNum > runtime.NumCPU() {
-		cpuNum = runtime.NumCPU()
+	results := make(BuildResults, len(compileBinaries))
+	var wg sync.WaitGroup
+	for i, binary := range compileBinaries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session.acquireJob()
+			defer session.releaseJob()
+			results[i] = compileBinary(ctx, session, filepath.Join(sourceDir, binary), outputDir, platform)
+		}()
 	}
-	const compilationUsage = 16
-	cpuNum = cpuNum / compilationUsage
-	if cpuNum%compilationUsage != 0 {
-		cpuNum++
+	wg.Wait()
+
+	return slices.DeleteFunc(results, func(r *BuildResult) bool { return r == nil })
+}
+
+// compileBinary compiles the main package found under binaryPath into outputDir. It returns nil if
+// there is no main package.
+func compileBinary(ctx context.Context, session *buildSession, binaryPath, outputDir, platform string) *BuildResult {
+	buildOpt := session.opt
+	start := time.Now()
+
+	result := &BuildResult{
+		Binary:   binarySourcePath(binaryPath),
+		Name:     binaryFileName(binaryOutputName(binarySourcePath(binaryPath)), platform),
+		Platform: platform,
+	}
+	fail := func(err error) *BuildResult {
+		session.failed.Store(true)
+		result.Status = BuildFailed
+		result.Err = err
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	// cancel reports a binary whose compilation was not started or not finished because ctx is done,
+	// removing its temporary output. The last good binary is kept.
+	var tmpPath string
+	cancel := func() *BuildResult {
+		if tmpPath != "" {
+			_ = os.Remove(tmpPath)
+		}
+		result.Status = BuildCancelled
+		result.Err = fmt.Errorf("%s for %s was cancelled", result.Name, platform)
+		result.Duration = time.Since(start)
+		return result
 	}
-	if cpuNum < 1 {
-		cpuNum = 1
+
+	if ctx.Err() != nil {
+		return cancel()
 	}
-	if len(compileBinaries) < cpuNum {
-		cpuNum = len(compileBinaries)
+	if session.aborted() {
+		result.Status = BuildAborted
+		result.Err = fmt.Errorf("%s for %s was not compiled because an earlier build failed", result.Name, platform)
+		return result
 	}
-	PrintGreen(fmt.Sprintf("The number of concurrent compilations is %d", cpuNum))
 
-	task := make(chan int, cpuNum)
-	go func() {
-		for i := range compileBinaries {
-			task <- i
-		}
-		close(task)
-	}()
+	dir, err := mainPackageDir(platformBuildContext(platform, buildOpt), binaryPath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to find main package in %s: %v", binaryPath, err))
+	}
+	if dir == "" {
+		PrintYellow(fmt.Sprintf("No main package in %s for platform %s, skipping", result.Binary, platform))
+		return nil
+	}
 
-	res := make(chan string, 1)
-	running := int64(cpuNum)
+	result.Binary = binarySourcePath(dir)
+	dirName := binaryOutputName(result.Binary)
+	outputFileName := binaryFileName(dirName, platform)
+	result.Name = outputFileName
 
-	env := map[string]string{
-		"GOOS":   targetOS,
-		"GOARCH": targetArch,
+	binOpt := buildOpt.ForBinary(dirName)
+	if _, ok := buildConfig.Binaries[dirName]; ok {
+		PrintBlue(fmt.Sprintf("Applying build overrides for %s", dirName))
+	}
+	if isToolSource(result.Binary) {
+		// Coverage and races are collected from services only, tools run once before them.
+		binOpt.Cover, binOpt.Race = nil, nil
 	}
-	if cgoEnabled != "" {
+	releaseEnabled := binOpt.GetRelease()
+	compressEnabled := binOpt.GetCompress()
+	env := map[string]string{}
+	maps.Copy(env, toolchainEnv(platform))
+	maps.Copy(env, platformEnv(platform))
+	if cgoEnabled := binOpt.GetCgoEnabled(); cgoEnabled != "" {
 		env["CGO_ENABLED"] = cgoEnabled
 	}
+	if binOpt.GetRace() {
+		// The race detector runtime requires cgo.
+		env["CGO_ENABLED"] = "1"
+	}
+
+	goModDir := util.FindGoModDir(dir)
+	if goModDir == "" {
+		goModDir = Paths.Root
+	} else {
+		PrintBlue(fmt.Sprintf("Found go.mod at: %s", goModDir))
+	}
+	result.Module = readModulePath(goModDir)
+	// Pin the workspace explicitly, so modules below the root resolve each other through go.work
+	// instead of the versions in their go.mod.
+	if ws := currentWorkspace(); ws != nil {
+		env["GOWORK"] = ws.File
+	}
+
+	outputPath := filepath.Join(outputDir, outputFileName)
+	result.Output = outputPath
+	// go build writes to a temporary file that replaces the binary once complete, so a running
+	// service never executes a partially written file.
+	tmpPath = tempOutputPath(outputPath)
 
-	baseDirAbs, err := filepath.Abs(Paths.Root)
+	relPath, err := filepath.Rel(goModDir, dir)
 	if err != nil {
-		PrintRed(fmt.Sprintf("Failed to get absolute path for root: %v", err))
-		os.Exit(1)
+		return fail(fmt.Errorf("failed to get relative path: %v", err))
 	}
 
-	for i := 0; i < cpuNum; i++ {
-		go func() {
-			defer func() {
-				if atomic.AddInt64(&running, -1) == 0 {
-					close(res)
-				}
-			}()
-
-			for index := range task {
-				originalDir := baseDirAbs
-
-				binaryPath := filepath.Join(sourceDir, compileBinaries[index])
-				path, err := util.FindMainGoFile(binaryPath)
-				if err != nil {
-					PrintYellow(fmt.Sprintf("Failed to walk through binary path %s: %v", binaryPath, err))
-					os.Exit(1)
-				}
-				if path == "" {
-					continue
-				}
-
-				dir := filepath.Dir(path)
-				dirName := filepath.Base(dir)
-				outputFileName := dirName
-				if targetOS == "windows" {
-					outputFileName += ".exe"
-				}
-
-				goModDir := util.FindGoModDir(dir)
-				if goModDir == "" {
-					goModDir = "."
-				} else {
-					PrintBlue(fmt.Sprintf("Found go.mod at: %s", goModDir))
-				}
-
-				if err := os.Chdir(goModDir); err != nil {
-					PrintRed(fmt.Sprintf("Failed to change directory to %s: %v", goModDir, err))
-					os.Chdir(originalDir)
-					continue
-				}
-
-				outputPath := filepath.Join(outputDir, outputFileName)
-
-				relPath, err := filepath.Rel(goModDir, path)
-				if err != nil {
-					PrintRed(fmt.Sprintf("Failed to get relative path: %v", err))
-					os.Exit(1)
-				}
-
-				buildTarget := relPath
-
-				PrintBlue(fmt.Sprintf("Compiling dir: %s for platform: %s binary: %s ...", dirName, platform, outputFileName))
-
-				buildArgs := []string{"build", "-o", outputPath}
-				if releaseEnabled {
-					PrintBlue("Building in release mode with optimizations...")
-					buildArgs = append(buildArgs, "-trimpath", "-ldflags", "-s -w")
-				}
-				buildArgs = append(buildArgs, buildTarget)
-
-				err = RunWithPriority(PriorityLow, env, "go", buildArgs...)
-
-				os.Chdir(originalDir)
-
-				if err != nil {
-					PrintRed("Compilation aborted. " + fmt.Sprintf("failed to compile %s for %s: %v", dirName, platform, err))
-					os.Exit(1)
-				}
-
-				PrintGreen(fmt.Sprintf("Successfully compiled. dir: %s for platform: %s binary: %s", dirName, platform, outputFileName))
-
-				if compressEnabled {
-					PrintBlue(fmt.Sprintf("Compressing %s with UPX...", outputFileName))
-					if err := RunWithPriority(PriorityLow, nil, "upx", "--lzma", outputPath); err != nil {
-						PrintYellow(fmt.Sprintf("UPX compression failed for %s (non-fatal): %v", outputFileName, err))
-					} else {
-						PrintGreen(fmt.Sprintf("Successfully compressed with UPX: %s", outputFileName))
-					}
-				}
-
-				res <- dirName
-			}
-		}()
+	// Build the package rather than a single file, so every file of the main package is compiled.
+	buildTarget := "./" + filepath.ToSlash(relPath)
+
+	PrintBlue(fmt.Sprintf("Compiling dir: %s for platform: %s binary: %s ...", dirName, platform, outputFileName))
+
+	if releaseEnabled {
+		PrintBlue("Building in release mode with optimizations...")
+	}
+	buildFlags, stableFlags := session.goBuildFlags(binOpt)
+	var steps []*PostBuildStepConfig
+	if session.postBuild {
+		steps = postBuildStepsFor(outputFileName, platform, compressEnabled)
+	}
+	buildArgs := append([]string{"build", "-o", tmpPath}, buildFlags...)
+	buildArgs = append(buildArgs, buildTarget)
+	entry := &ManifestEntry{
+		Binary:    result.Binary,
+		Platform:  platform,
+		Output:    outputPath,
+		Flags:     manifestFlags(stableFlags, env, steps),
+		GoVersion: session.goVersion,
+		Version:   session.version,
+	}
+	if session.goVersion != "" {
+		if entry.InputsHash, err = hashPackageInputs(dir, env, binOpt.GetTags()); err != nil {
+			PrintYellow(fmt.Sprintf("Failed to hash inputs of %s, rebuilding: %v", dirName, err))
+		}
+	}
+	if !buildOpt.GetForce() && entry.InputsHash != "" && session.manifest.UpToDate(entry) {
+		PrintGreen(fmt.Sprintf("Up to date, skipping. dir: %s for platform: %s binary: %s", dirName, platform, outputFileName))
+		result.Status = BuildUpToDate
+		result.Size = fileSize(outputPath)
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	binCtx := ctx
+	if timeout := binOpt.GetTimeout(); timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		binCtx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+	timedOut := func() error {
+		if errors.Is(binCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("%s for %s timed out after %s", dirName, platform, binOpt.GetTimeout())
+		}
+		return nil
 	}
 
-	compiledDirs := make([]string, 0, len(compileBinaries))
-	for str := range res {
-		compiledDirs = append(compiledDirs, str)
+	// The compiler output is captured per binary so concurrent builds do not interleave it.
+	var compilerOutput bytes.Buffer
+	// GOTMPDIR is not part of env, which is recorded in the manifest.
+	runEnv := maps.Clone(env)
+	runEnv["GOTMPDIR"] = session.tmpDir
+	runOpt := RunOptions{Priority: PriorityLow, Dir: goModDir, Env: runEnv, Stdout: &compilerOutput, Stderr: &compilerOutput}
+	if buildOpt.GetReproducible() {
+		runOpt.BaseEnv = reproducibleEnv()
+	}
+	err = RunWithOptions(binCtx, runOpt, "go", buildArgs...)
+	result.CompilerOutput = compilerOutput.String()
+	if ctx.Err() != nil {
+		return cancel()
+	}
+	if timeoutErr := timedOut(); timeoutErr != nil {
+		_ = os.Remove(tmpPath)
+		return fail(timeoutErr)
+	}
+	if err != nil {
+		PrintRed(fmt.Sprintf("Failed to compile %s for %s: %v", dirName, platform, err))
+		// The last good binary is kept, the failed build only leaves its temporary output behind.
+		_ = os.Remove(tmpPath)
+		return fail(fmt.Errorf("failed to compile %s for %s: %v", dirName, platform, err))
+	}
+
+	PrintGreen(fmt.Sprintf("Successfully compiled. dir: %s for platform: %s binary: %s", dirName, platform, outputFileName))
+	// The SBOM is derived from the build info, which compression may strip from the binary.
+	if result.buildInfo, err = buildinfo.ReadFile(tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fail(fmt.Errorf("failed to read build info of %s for %s: %v", dirName, platform, err))
+	}
+
+	// The pipeline runs on the temporary output, the binary is only replaced once every step passed.
+	artifact := &PostBuildArtifact{Binary: result.Binary, Name: outputFileName, Platform: platform, Path: tmpPath}
+	result.Steps, err = runPostBuildSteps(binCtx, artifact, steps)
+	if ctx.Err() != nil {
+		return cancel()
+	}
+	if timeoutErr := timedOut(); timeoutErr != nil {
+		_ = os.Remove(tmpPath)
+		return fail(timeoutErr)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fail(err)
+	}
+	if err := replaceBinary(tmpPath, outputPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fail(fmt.Errorf("failed to replace %s: %v", outputPath, err))
 	}
-	return compiledDirs
+
+	if entry.InputsHash != "" {
+		entry.BuiltAt = time.Now()
+		session.manifest.Record(entry)
+	}
+	result.Status = BuildSucceeded
+	result.Size = fileSize(outputPath)
+	result.Duration = time.Since(start)
+	return result
+}
+
+// binarySourcePath returns the root-relative source path of the binary directory.
+func binarySourcePath(dir string) string {
+	rel, err := filepath.Rel(Paths.Root, dir)
+	if err != nil {
+		return dir
+	}
+	return rel
+}
+
+func binaryFileName(name, platform string) string {
+	if strings.HasPrefix(platform, "windows_") {
+		return name + ".exe"
+	}
+	return name
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
 }
 
-func createStartConfigYML(cmdDirs, toolsDirs []string) {
+// manifestFlags lists the build flags, environment and post-build steps that affect the output of a binary.
+func manifestFlags(buildFlags []string, env map[string]string, steps []*PostBuildStepConfig) []string {
+	flags := slices.Clone(buildFlags)
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		flags = append(flags, k+"="+env[k])
+	}
+	if goFlags := os.Getenv("GOFLAGS"); goFlags != "" {
+		flags = append(flags, "GOFLAGS="+goFlags)
+	}
+	for _, step := range steps {
+		flags = append(flags, step.signature())
+	}
+	return flags
+}
+
+// createStartConfigYML writes a default start-config.yml listing the binaries that were built.
+func createStartConfigYML(results BuildResults) {
+	var cmdDirs, toolsDirs []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		name := strings.TrimSuffix(r.Name, ".exe")
+		if !r.OK() || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if r.Tool {
+			toolsDirs = append(toolsDirs, name)
+		} else {
+			cmdDirs = append(cmdDirs, name)
+		}
+	}
+
 	configPath := filepath.Join(Paths.Root, StartConfigFile)
 
 	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
@@ -292,13 +760,39 @@ func ResolveBuildOptions(codeOpt *BuildOptions, envOpt *BuildOptions) *BuildOpti
 		Release:    util.CoalescePtr(fromCode.Release, fromEnv.Release),
 		Compress:   util.CoalescePtr(fromCode.Compress, fromEnv.Compress),
 		Platforms:  util.CoalescePtr(fromCode.Platforms, fromEnv.Platforms),
+		Force:      util.CoalescePtr(fromCode.Force, fromEnv.Force),
+
+		VersionStamp:   util.CoalescePtr(fromCode.VersionStamp, fromEnv.VersionStamp),
+		VersionPackage: util.CoalescePtr(fromCode.VersionPackage, fromEnv.VersionPackage),
+
+		Tags:    util.CoalescePtr(fromCode.Tags, fromEnv.Tags),
+		GCFlags: util.CoalescePtr(fromCode.GCFlags, fromEnv.GCFlags),
+		LDFlags: util.CoalescePtr(fromCode.LDFlags, fromEnv.LDFlags),
+
+		Jobs:      util.CoalescePtr(fromCode.Jobs, fromEnv.Jobs),
+		KeepGoing: util.CoalescePtr(fromCode.KeepGoing, fromEnv.KeepGoing),
+
+		Reproducible: util.CoalescePtr(fromCode.Reproducible, fromEnv.Reproducible),
+		VerifyRepro:  util.CoalescePtr(fromCode.VerifyRepro, fromEnv.VerifyRepro),
+
+		Cover:    util.CoalescePtr(fromCode.Cover, fromEnv.Cover),
+		CoverPkg: util.CoalescePtr(fromCode.CoverPkg, fromEnv.CoverPkg),
+		Race:     util.CoalescePtr(fromCode.Race, fromEnv.Race),
+
+		ChangedSince: util.CoalescePtr(fromCode.ChangedSince, fromEnv.ChangedSince),
+
+		Timeout: util.CoalescePtr(fromCode.Timeout, fromEnv.Timeout),
+		Restart: util.CoalescePtr(fromCode.Restart, fromEnv.Restart),
 	}
 }
 
-func getBinaries(binaries []string) []string {
+// getBinaries returns the root-relative source paths of the requested binaries, or of all binaries
+// with a main package for one of the platforms in opt when none are requested.
+func getBinaries(binaries []string, opt *BuildOptions) ([]string, error) {
 	if len(binaries) > 0 {
-		return resolveRequestedBinaries(binaries)
+		return resolveRequestedBinaries(binaries, opt)
 	}
+	contexts := discoveryContexts(opt)
 
 	type binarySource struct {
 		baseDir string
@@ -309,10 +803,22 @@ func getBinaries(binaries []string) []string {
 		{baseDir: filepath.Join(Paths.Root, Paths.SrcDir), prefix: normalizedSourcePrefix(Paths.SrcDir)},
 		{baseDir: filepath.Join(Paths.Root, Paths.ToolsDir), prefix: normalizedSourcePrefix(Paths.ToolsDir)},
 	}
+	// The cmd and tools directories of the other workspace modules are laid out like the root module.
+	if ws := currentWorkspace(); ws != nil {
+		for _, mod := range ws.Modules {
+			if mod.Dir == "." {
+				continue
+			}
+			sources = append(sources,
+				binarySource{baseDir: filepath.Join(Paths.Root, mod.Dir, Paths.SrcDir), prefix: withSourcePrefix(mod.Dir, normalizedSourcePrefix(Paths.SrcDir))},
+				binarySource{baseDir: filepath.Join(Paths.Root, mod.Dir, Paths.ToolsDir), prefix: withSourcePrefix(mod.Dir, normalizedSourcePrefix(Paths.ToolsDir))},
+			)
+		}
+	}
 
 	var allBinaries []string
 	for _, source := range sources {
-		dirs, err := getSubDirectoriesBFS(source.baseDir)
+		dirs, err := getSubDirectoriesBFS(source.baseDir, contexts)
 		if err != nil {
 			PrintYellow(fmt.Sprintf("Failed to glob pattern %s: %v", source.baseDir, err))
 			continue
@@ -323,10 +829,10 @@ func getBinaries(binaries []string) []string {
 		}
 	}
 
-	return allBinaries
+	return allBinaries, nil
 }
 
-func getSubDirectoriesBFS(baseDir string) ([]string, error) {
+func getSubDirectoriesBFS(baseDir string, contexts []*build.Context) ([]string, error) {
 	entries, err := os.ReadDir(baseDir)
 	if err != nil {
 		return nil, err
@@ -344,11 +850,12 @@ func getSubDirectoriesBFS(baseDir string) ([]string, error) {
 	for i := 0; i < len(queue); i++ {
 		currentDir := queue[i]
 
-		if util.ContainsMainGo(currentDir) {
+		if isMainPackageForAny(contexts, currentDir) {
 			relPath, err := filepath.Rel(baseDir, currentDir)
 			if err == nil {
 				subDirs = append(subDirs, relPath)
 			}
+			warnNestedMainPackages(contexts, currentDir)
 			continue
 		}
 
@@ -374,9 +881,33 @@ func getSubDirectoriesBFS(baseDir string) ([]string, error) {
 	return subDirs, nil
 }
 
-func resolveRequestedBinaries(binaries []string) []string {
+// resolveRequestedBinaries resolves the binary names, globs and @groups passed on the command line
+// to root-relative source paths.
+func resolveRequestedBinaries(binaries []string, opt *BuildOptions) ([]string, error) {
 	var resolved []string
+	discovered, err := getBinaries(nil, opt)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(discovered))
+	for _, binary := range discovered {
+		names = append(names, binaryOutputName(binary))
+	}
+	// Directory names below cmd and tools are accepted too, for binaries laid out in nested directories.
+	binaries, err = expandBinaryNames(binaries, names, func(name string) bool {
+		_, isCmd := isCmdBinary(name)
+		_, isTool := isToolBinary(name)
+		return isCmd || isTool
+	})
+	if err != nil {
+		return nil, err
+	}
 	for _, binary := range binaries {
+		// Output names take precedence, they are what start-config.yml and the output directories use.
+		if matches := binariesWithOutputName(discovered, strings.TrimSuffix(binary, ".exe")); len(matches) > 0 {
+			resolved = append(resolved, matches...)
+			continue
+		}
 		if path, found := isCmdBinary(binary); found {
 			resolved = append(resolved, path)
 			continue
@@ -385,10 +916,20 @@ func resolveRequestedBinaries(binaries []string) []string {
 			resolved = append(resolved, path)
 			continue
 		}
-		PrintYellow(fmt.Sprintf("Binary %s not found in cmd (%s) or tools (%s) directories. Skipping...", binary, Paths.SrcDir, Paths.ToolsDir))
+		return nil, fmt.Errorf("binary %s not found in cmd (%s) or tools (%s) directories", binary, Paths.SrcDir, Paths.ToolsDir)
 	}
 	fmt.Println("Resolved binaries:", resolved)
-	return resolved
+	return resolved, nil
+}
+
+func binariesWithOutputName(binaries []string, name string) []string {
+	var matches []string
+	for _, binary := range binaries {
+		if binaryOutputName(binary) == name {
+			matches = append(matches, binary)
+		}
+	}
+	return matches
 }
 
 func normalizedSourcePrefix(prefix string) string {
@@ -0,0 +1,130 @@
+package mageutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// moduleFiles change the dependencies of every package, a change to any of them selects all binaries.
+var moduleFiles = []string{"go.mod", "go.sum", "go.work", "go.work.sum"}
+
+// changedFiles returns the absolute paths of the files changed on HEAD since it forked from ref, like
+// ref...HEAD, and the changes in the working tree, including deleted and untracked files. Changes made
+// on ref after the fork are not included.
+func changedFiles(ref string) ([]string, error) {
+	top, err := gitOutput(Paths.Root, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	base, err := gitOutput(top, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	// Renames are listed as a deletion and an addition, so both packages are considered changed. Names
+	// are NUL terminated so that git neither quotes nor escapes them.
+	diff, err := gitOutput(top, "diff", "--name-only", "--no-renames", "-z", base, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitOutput(top, "ls-files", "--others", "--exclude-standard", "--full-name", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	output := filepath.Clean(Paths.Output)
+	for _, name := range strings.Split(diff+"\x00"+untracked, "\x00") {
+		if name == "" {
+			continue
+		}
+		// Build outputs are not sources, even when the output directory is not ignored by git.
+		if file := filepath.Join(top, filepath.FromSlash(name)); !isSubPath(output, file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// selectChangedBinaries returns the binaries whose dependency graph, on any of the platforms, contains
+// a file changed since ref. A binary whose dependencies cannot be listed is selected.
+func selectChangedBinaries(binaries []string, ref string, platforms []string, opt *BuildOptions) ([]string, error) {
+	files, err := changedFiles(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %s: %w", ref, err)
+	}
+	PrintBlue(fmt.Sprintf("%d files changed since %s", len(files), ref))
+
+	changedDirs := make(map[string]bool)
+	changed := make(map[string]bool)
+	for _, file := range files {
+		if slices.Contains(moduleFiles, filepath.Base(file)) {
+			PrintBlue(fmt.Sprintf("%s changed since %s, selecting all binaries", binarySourcePath(file), ref))
+			return binaries, nil
+		}
+		changed[file] = true
+		// Any non-test file in a package directory may be part of the package on some platform.
+		if !strings.HasSuffix(file, "_test.go") {
+			changedDirs[filepath.Dir(file)] = true
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var selected []string
+	for _, binary := range binaries {
+		affected, err := binaryAffected(binary, platforms, opt, changedDirs, changed)
+		if err != nil {
+			PrintYellow(fmt.Sprintf("Selecting %s, failed to list its dependencies: %v", binary, err))
+			affected = true
+		}
+		if affected {
+			selected = append(selected, binary)
+		}
+	}
+	return selected, nil
+}
+
+func binaryAffected(binary string, platforms []string, opt *BuildOptions, changedDirs, changed map[string]bool) (bool, error) {
+	binOpt := opt.ForBinary(binaryOutputName(binary))
+	for _, platform := range platforms {
+		dir, err := mainPackageDir(platformBuildContext(platform, binOpt), filepath.Join(Paths.Root, binary))
+		if err != nil {
+			return false, err
+		}
+		if dir == "" {
+			continue
+		}
+
+		env := platformEnv(platform)
+		if cgoEnabled := binOpt.GetCgoEnabled(); cgoEnabled != "" {
+			env["CGO_ENABLED"] = cgoEnabled
+		}
+		if ws := currentWorkspace(); ws != nil {
+			env["GOWORK"] = ws.File
+		}
+		pkgs, err := goListDeps(dir, env, binOpt.GetTags())
+		if err != nil {
+			return false, err
+		}
+		for _, pkg := range pkgs {
+			if pkg.Standard {
+				continue
+			}
+			pkgDir := filepath.Clean(pkg.Dir)
+			if changedDirs[pkgDir] {
+				return true, nil
+			}
+			// Embedded files may live in subdirectories of the package.
+			for _, file := range pkg.EmbedFiles {
+				if changed[filepath.Join(pkgDir, filepath.FromSlash(file))] {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,51 @@
+package mageutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestChangedFilesNonASCII(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := useTempPaths(t)
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := gitOutput(root, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("main.go")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+	write("配置.go")
+	git("add", "-A")
+	git("commit", "-q", "-m", "non-ascii")
+	write("未跟踪 file.go")
+
+	files, err := changedFiles("HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(top, "配置.go"), filepath.Join(top, "未跟踪 file.go")}
+	if !slices.Equal(files, want) {
+		t.Errorf("changedFiles = %q, want %q", files, want)
+	}
+}
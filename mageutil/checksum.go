@@ -0,0 +1,96 @@
+package mageutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const ChecksumsFile = "SHA256SUMS"
+
+// fileSHA256 returns the hex encoded SHA-256 digest of the file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteChecksums writes a SHA256SUMS file in dir covering the regular files accepted by include,
+// all files when include is nil. The format is the one read by `sha256sum -c`.
+func WriteChecksums(dir string, include func(name string) bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %v", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == ChecksumsFile || (include != nil && !include(name)) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		sum, err := fileSHA256(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sum, name)
+	}
+
+	path := filepath.Join(dir, ChecksumsFile)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeBuildChecksums records the digest of every built binary and rewrites the SHA256SUMS file of
+// each output directory a binary was written to.
+func writeBuildChecksums(results BuildResults) error {
+	dirs := make(map[string]struct{})
+	for _, r := range results {
+		if !r.OK() {
+			continue
+		}
+		sum, err := fileSHA256(r.Output)
+		if err != nil {
+			return err
+		}
+		r.SHA256 = sum
+		dirs[filepath.Dir(r.Output)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := WriteChecksums(dir, isBinaryOutput); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBinaryOutput reports whether a file of a binary output directory is a binary. Binaries being
+// written by a concurrent build and the files written by the checksum step are not.
+func isBinaryOutput(name string) bool {
+	if isTempOutput(name) || name == ChecksumsFile {
+		return false
+	}
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	return ext == "" || ext == "exe" || checksumHash(ext) == nil
+}
@@ -0,0 +1,133 @@
+package mageutil
+
+import (
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	CoverageProfileFile = "coverage.out"
+	CoverageHTMLFile    = "coverage.html"
+	coverageMergedDir   = "merged"
+)
+
+// builtWithFlag reports whether the binary at path was built with a boolean build flag such as -cover,
+// which the toolchain records in the build settings of the binary. The build manifest is consulted for
+// binaries whose build info is unreadable, such as UPX compressed ones.
+func builtWithFlag(path, flag string) bool {
+	if info, err := buildinfo.ReadFile(path); err == nil {
+		for _, setting := range info.Settings {
+			if setting.Key == flag {
+				return setting.Value == "true"
+			}
+		}
+		return false
+	}
+	manifest := LoadBuildManifest()
+	path = filepath.Clean(path)
+	for _, entry := range manifest.Entries {
+		if filepath.Clean(entry.Output) == path {
+			return slices.Contains(entry.Flags, flag)
+		}
+	}
+	return false
+}
+
+func coverageServiceDir(binary string) string {
+	return filepath.Join(Paths.OutputCoverage, strings.TrimSuffix(binary, ".exe"))
+}
+
+// coverageDir returns the GOCOVERDIR of one instance of a service, creating it if needed.
+func coverageDir(binary string, index int) (string, error) {
+	dir := filepath.Join(coverageServiceDir(binary), strconv.Itoa(index))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create coverage directory %s: %v", dir, err)
+	}
+	return dir, nil
+}
+
+// coverageDataDirs returns the instance directories under the coverage output holding coverage data.
+func coverageDataDirs() ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(Paths.OutputCoverage, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == coverageMergedDir && filepath.Dir(path) == filepath.Clean(Paths.OutputCoverage) {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), "covmeta.") {
+			dir := filepath.Dir(path)
+			if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return dirs, err
+}
+
+// hasCounterData reports whether a coverage directory contains counter files, which a binary only
+// writes when it exits normally.
+func hasCounterData(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "covcounters.*"))
+	return len(matches) > 0
+}
+
+// MergeCoverage merges the coverage data written by the stopped services into a text profile and
+// an HTML report under the coverage output directory.
+func MergeCoverage() error {
+	InitForSSC()
+	if err := CheckBinariesStop(); err != nil {
+		return fmt.Errorf("services are still running, stop them first with mage stop: %v", err)
+	}
+
+	dirs, err := coverageDataDirs()
+	if err != nil {
+		return fmt.Errorf("failed to read coverage directory %s: %v", Paths.OutputCoverage, err)
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("no coverage data in %s, build with --cover and run the services first", Paths.OutputCoverage)
+	}
+	slices.Sort(dirs)
+	for _, dir := range dirs {
+		if !hasCounterData(dir) {
+			PrintYellow(fmt.Sprintf("No coverage counters in %s, the service did not exit normally", dir))
+		}
+	}
+
+	merged := filepath.Join(Paths.OutputCoverage, coverageMergedDir)
+	if err := os.RemoveAll(merged); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", merged, err)
+	}
+	if err := os.MkdirAll(merged, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", merged, err)
+	}
+
+	profile := filepath.Join(Paths.OutputCoverage, CoverageProfileFile)
+	report := filepath.Join(Paths.OutputCoverage, CoverageHTMLFile)
+	steps := [][]string{
+		{"tool", "covdata", "merge", "-i=" + strings.Join(dirs, ","), "-o", merged},
+		{"tool", "covdata", "textfmt", "-i=" + merged, "-o", profile},
+		{"tool", "covdata", "percent", "-i=" + merged},
+		// go tool cover resolves the packages of the profile, so it runs in the module root.
+		{"tool", "cover", "-html=" + profile, "-o", report},
+	}
+	for _, args := range steps {
+		if err := RunWithOptions(context.Background(), RunOptions{Dir: Paths.Root}, "go", args...); err != nil {
+			return fmt.Errorf("go %s failed: %v", strings.Join(args[:2], " "), err)
+		}
+	}
+
+	PrintGreen(fmt.Sprintf("Merged coverage of %d instances into %s and %s", len(dirs), profile, report))
+	return nil
+}
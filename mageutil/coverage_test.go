@@ -0,0 +1,43 @@
+package mageutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltWithFlag(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/hello\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		flags []string
+		cover bool
+	}{
+		{name: "plain"},
+		{name: "cover", flags: []string{"-cover"}, cover: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(dir, tt.name)
+			cmd := exec.Command("go", append(append([]string{"build", "-o", output}, tt.flags...), ".")...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("go build failed: %v\n%s", err, out)
+			}
+			if got := builtWithFlag(output, "-cover"); got != tt.cover {
+				t.Errorf("builtWithFlag(-cover) = %v, want %v", got, tt.cover)
+			}
+			if builtWithFlag(output, "-race") {
+				t.Errorf("builtWithFlag(-race) = true, want false")
+			}
+		})
+	}
+}
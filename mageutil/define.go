@@ -19,9 +19,12 @@ var (
 )
 
 type Config struct {
-	ServiceBinaries    map[string]int `yaml:"serviceBinaries"`
-	ToolBinaries       []string       `yaml:"toolBinaries"`
-	MaxFileDescriptors int            `yaml:"maxFileDescriptors"`
+	ServiceBinaries    map[string]int      `yaml:"serviceBinaries"`
+	ToolBinaries       []string            `yaml:"toolBinaries"`
+	MaxFileDescriptors int                 `yaml:"maxFileDescriptors"`
+	Hooks              HooksConfig         `yaml:"hooks"`
+	Build              BuildConfig         `yaml:"build"`
+	Groups             map[string][]string `yaml:"groups"` // Named groups of binaries, selected with @name
 }
 
 func InitForSSC() {
@@ -56,4 +59,7 @@ func InitForSSC() {
 	serviceBinaries = adjustedBinaries
 	toolBinaries = adjustedToolsBinaries
 	MaxFileDescriptors = config.MaxFileDescriptors
+	hooksConfig = config.Hooks
+	buildConfig = config.Build
+	binaryGroups = config.Groups
 }
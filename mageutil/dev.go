@@ -0,0 +1,274 @@
+package mageutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	devPollInterval = 500 * time.Millisecond
+	devDebounce     = time.Second
+)
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+type devWatcher struct {
+	platform string
+	opt      *BuildOptions       // Build options resolved from the environment, used to evaluate build constraints
+	binaries []string            // Root-relative source paths of the watched binaries
+	depIndex map[string][]string // Package directory -> binaries depending on it
+	snapshot map[string]fileStamp
+}
+
+// Dev builds and starts the given binaries (all when empty), then watches their sources and the local
+// packages they depend on. Changed binaries are rebuilt and their service instances restarted.
+func Dev(binaries []string) error {
+	platform := DetectPlatform()
+	opt := resolveBuildOptionsWithEnv(&BuildOptions{Platforms: &[]string{platform}})
+	targets, err := getBinaries(binaries, opt)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no binaries found to watch")
+	}
+
+	w := &devWatcher{platform: platform, opt: opt, binaries: targets}
+	w.build(targets)
+	StartToolsAndServices(binaries, nil)
+
+	w.refreshIndex()
+	w.snapshot = w.scan()
+	PrintGreen(fmt.Sprintf("Watching %d binaries for changes, press Ctrl+C to exit.", len(targets)))
+
+	ticker := time.NewTicker(devPollInterval)
+	defer ticker.Stop()
+
+	pending := make(map[string]struct{})
+	var lastChange time.Time
+	for range ticker.C {
+		current := w.scan()
+		for _, file := range diffSnapshots(w.snapshot, current) {
+			pending[file] = struct{}{}
+			lastChange = time.Now()
+		}
+		w.snapshot = current
+
+		// Wait for the sources to settle, changes made during a rebuild are picked up by the next scan.
+		if len(pending) == 0 || time.Since(lastChange) < devDebounce {
+			continue
+		}
+		changed := make([]string, 0, len(pending))
+		for file := range pending {
+			changed = append(changed, file)
+		}
+		pending = make(map[string]struct{})
+		sort.Strings(changed)
+		w.handleChanges(changed)
+	}
+	return nil
+}
+
+func (w *devWatcher) handleChanges(changed []string) {
+	for _, file := range changed {
+		rel, err := filepath.Rel(Paths.Root, file)
+		if err != nil {
+			rel = file
+		}
+		PrintBlue(fmt.Sprintf("Changed: %s", rel))
+	}
+
+	affected := w.affectedBinaries(changed)
+	if len(affected) == 0 {
+		PrintYellow("Changes do not affect any watched binary.")
+		w.refreshIndex()
+		return
+	}
+
+	results := w.build(affected)
+
+	// Only restart the services that were rebuilt, a failed build keeps the running instances.
+	var services []string
+	for _, r := range results {
+		if r.Status == BuildSucceeded && isServiceBinary(r.Name) {
+			services = append(services, r.Name)
+		}
+	}
+	if len(services) > 0 {
+		if err := restartServices(services); err != nil {
+			PrintRed(err.Error())
+		}
+	}
+
+	// New imports may have changed the dependency graph.
+	w.refreshIndex()
+}
+
+func (w *devWatcher) build(binaries []string) BuildResults {
+	names := make([]string, 0, len(binaries))
+	for _, binary := range binaries {
+		names = append(names, binaryOutputName(binary))
+	}
+	keepGoing := true
+	results, err := Build(names, nil, &BuildOptions{Platforms: &[]string{w.platform}, KeepGoing: &keepGoing})
+	if err != nil {
+		PrintRed(fmt.Sprintf("Build failed, waiting for further changes: %v", err))
+	}
+	return results
+}
+
+// affectedBinaries maps changed files to the binaries whose dependency graph contains them.
+// A change to go.mod, go.sum or go.work affects every binary.
+func (w *devWatcher) affectedBinaries(changed []string) []string {
+	set := make(map[string]struct{})
+	for _, file := range changed {
+		switch filepath.Base(file) {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+			return w.binaries
+		}
+		for _, binary := range w.depIndex[filepath.Dir(file)] {
+			set[binary] = struct{}{}
+		}
+	}
+
+	affected := make([]string, 0, len(set))
+	for binary := range set {
+		affected = append(affected, binary)
+	}
+	sort.Strings(affected)
+	return affected
+}
+
+func (w *devWatcher) refreshIndex() {
+	index := make(map[string][]string)
+	env := platformEnv(w.platform)
+	root := filepath.Clean(Paths.Root)
+	ctx := platformBuildContext(w.platform, w.opt)
+
+	for _, binary := range w.binaries {
+		mainDir, err := mainPackageDir(ctx, filepath.Join(Paths.Root, binary))
+		if err != nil || mainDir == "" {
+			PrintYellow(fmt.Sprintf("Failed to find main package of %s: %v", binary, err))
+			continue
+		}
+		pkgs, err := goListDeps(mainDir, env, w.opt.GetTags())
+		if err != nil {
+			PrintYellow(fmt.Sprintf("Failed to list dependencies of %s: %v", binary, err))
+			continue
+		}
+		for _, pkg := range pkgs {
+			dir := filepath.Clean(pkg.Dir)
+			if pkg.Standard || !isSubPath(root, dir) {
+				continue
+			}
+			index[dir] = append(index[dir], binary)
+		}
+	}
+	w.depIndex = index
+}
+
+// scan collects the files under the source and tools directories, the local dependency directories
+// and the module files in the root directory.
+func (w *devWatcher) scan() map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	output := filepath.Clean(Paths.Output)
+
+	addFile := func(path string, info fs.FileInfo) {
+		files[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for _, dir := range []string{filepath.Join(Paths.Root, Paths.SrcDir), filepath.Join(Paths.Root, Paths.ToolsDir)} {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && (strings.HasPrefix(d.Name(), ".") || filepath.Clean(path) == output) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				addFile(path, info)
+			}
+			return nil
+		})
+	}
+
+	for dir := range w.depIndex {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				addFile(filepath.Join(dir, entry.Name()), info)
+			}
+		}
+	}
+
+	for _, name := range []string{"go.mod", "go.sum", "go.work", "go.work.sum"} {
+		path := filepath.Join(Paths.Root, name)
+		if info, err := os.Stat(path); err == nil {
+			addFile(path, info)
+		}
+	}
+	return files
+}
+
+func diffSnapshots(prev, current map[string]fileStamp) []string {
+	var changed []string
+	for path, stamp := range current {
+		if old, ok := prev[path]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// restartServices stops the running instances of the given services, the same way mage stop does, and
+// starts them again.
+func restartServices(services []string) error {
+	if err := stopServices(services); err != nil {
+		return err
+	}
+	if err := RunHooks(HookPostStop, services); err != nil {
+		return err
+	}
+
+	if err := StartBinaries(services...); err != nil {
+		return fmt.Errorf("failed to restart services: %w", err)
+	}
+	PrintGreen(fmt.Sprintf("Restarted services: %s", strings.Join(services, ", ")))
+
+	return RunHooks(HookPostStart, services)
+}
+
+func isServiceBinary(name string) bool {
+	_, exists := serviceBinaries[name]
+	return exists
+}
+
+func isSubPath(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,97 @@
+package mageutil
+
+import (
+	"fmt"
+	"go/build"
+	"path/filepath"
+	"strings"
+
+	"github.com/openimsdk/gomake/internal/util"
+)
+
+// platformBuildContext returns the context used to evaluate build constraints for the platform.
+func platformBuildContext(platform string, opt *BuildOptions) *build.Context {
+	goos, goarch, _ := splitPlatform(platform)
+	cgoEnabled := util.DefaultCgoEnabled(goos, goarch)
+	if cgo := opt.GetCgoEnabled(); cgo != "" {
+		cgoEnabled = cgo == "1"
+	}
+	return util.BuildContext(goos, goarch, cgoEnabled, opt.GetTags())
+}
+
+// discoveryContexts returns the build contexts of the platforms being built. A directory is discovered
+// as a binary if it holds a main package for any of them.
+func discoveryContexts(opt *BuildOptions) []*build.Context {
+	platforms := opt.GetPlatforms()
+	if len(platforms) == 0 {
+		platforms = []string{DetectPlatform()}
+	}
+	contexts := make([]*build.Context, 0, len(platforms))
+	for _, platform := range platforms {
+		contexts = append(contexts, platformBuildContext(platform, opt))
+	}
+	return contexts
+}
+
+func isMainPackageForAny(contexts []*build.Context, dir string) bool {
+	for _, ctx := range contexts {
+		isMain, err := util.IsMainPackage(ctx, dir)
+		if err != nil {
+			PrintYellow(fmt.Sprintf("Failed to inspect package %s for %s_%s: %v", dir, ctx.GOOS, ctx.GOARCH, err))
+			continue
+		}
+		if isMain {
+			return true
+		}
+	}
+	return false
+}
+
+// mainPackageDir returns the main package to build for binaryPath: binaryPath itself, or the only main
+// package below it. It returns an empty string if there is none and an error if there are several.
+func mainPackageDir(ctx *build.Context, binaryPath string) (string, error) {
+	isMain, err := util.IsMainPackage(ctx, binaryPath)
+	if err != nil {
+		return "", err
+	}
+	if isMain {
+		return binaryPath, nil
+	}
+
+	dirs, err := util.FindMainPackages(ctx, binaryPath)
+	if err != nil {
+		return "", err
+	}
+	switch len(dirs) {
+	case 0:
+		return "", nil
+	case 1:
+		return dirs[0], nil
+	default:
+		rel := make([]string, 0, len(dirs))
+		for _, dir := range dirs {
+			rel = append(rel, binarySourcePath(dir))
+		}
+		return "", fmt.Errorf("%s is ambiguous, it contains %d main packages: %s; build them by name instead",
+			binarySourcePath(binaryPath), len(dirs), strings.Join(rel, ", "))
+	}
+}
+
+// warnNestedMainPackages reports main packages below the binary directory dir, which are not discovered
+// because discovery stops at the first main package of a path.
+func warnNestedMainPackages(contexts []*build.Context, dir string) {
+	if len(contexts) == 0 {
+		return
+	}
+	dirs, err := util.FindMainPackages(contexts[0], dir)
+	if err != nil {
+		return
+	}
+	for _, nested := range dirs {
+		if nested == dir {
+			continue
+		}
+		PrintYellow(fmt.Sprintf("Main package %s is nested in binary %s and is not built by default, build it by name (%s) or move it out",
+			binarySourcePath(nested), binarySourcePath(dir), filepath.Base(nested)))
+	}
+}
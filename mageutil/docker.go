@@ -0,0 +1,252 @@
+package mageutil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openimsdk/gomake/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DockerComposeFile     = "docker-compose.yml"
+	defaultDockerBase     = "alpine:3.20"
+	dockerAppDir          = "/app"
+	dockerConfigMountPath = "/app/config/"
+	dockerGeneratedHeader = "# Code generated by gomake. DO NOT EDIT.\n"
+)
+
+type DockerOptions struct {
+	BaseImage *string // Base image of the generated Dockerfiles, default is "alpine:3.20"
+	Arch      *string // Linux architecture of the binaries to package, default is the host architecture
+}
+
+func (opt *DockerOptions) GetBaseImage() string {
+	baseImage := strings.TrimSpace(util.NilAsZero(util.NilAsZero(opt).BaseImage))
+	if baseImage == "" {
+		return defaultDockerBase
+	}
+	return baseImage
+}
+
+func (opt *DockerOptions) GetArch() string {
+	arch := strings.TrimSpace(util.NilAsZero(util.NilAsZero(opt).Arch))
+	if arch == "" {
+		return runtime.GOARCH
+	}
+	return arch
+}
+
+func ResolveDockerOptions(codeOpt *DockerOptions, envOpt *DockerOptions) *DockerOptions {
+	fromCode := DockerOptions{}
+	if codeOpt != nil {
+		fromCode = *codeOpt
+	}
+
+	fromEnv := DockerOptions{}
+	if envOpt != nil {
+		fromEnv = *envOpt
+	}
+
+	return &DockerOptions{
+		BaseImage: util.CoalescePtr(fromCode.BaseImage, fromEnv.BaseImage),
+		Arch:      util.CoalescePtr(fromCode.Arch, fromEnv.Arch),
+	}
+}
+
+type composeFile struct {
+	Services map[string]*composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Build     composeBuild                   `yaml:"build"`
+	Platform  string                         `yaml:"platform"`
+	Command   []string                       `yaml:"command,omitempty"`
+	Volumes   []string                       `yaml:"volumes,omitempty"`
+	Restart   string                         `yaml:"restart"`
+	DependsOn map[string]composeDependsOnOpt `yaml:"depends_on,omitempty"`
+}
+
+type composeBuild struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+type composeDependsOnOpt struct {
+	Condition string `yaml:"condition"`
+}
+
+// GenerateDockerArtifacts writes a Dockerfile per service and tool together with a docker-compose.yml
+// into the docker output directory, packaging the linux binaries that have already been built.
+func GenerateDockerArtifacts(dockerOpt *DockerOptions) error {
+	resolvedOpt := ResolveDockerOptions(dockerOpt, &DockerOptions{
+		BaseImage: util.ResolveEnvOption[string]("DOCKER_BASE_IMAGE"),
+		Arch:      util.ResolveEnvOption[string]("DOCKER_ARCH"),
+	})
+	InitForSSC()
+
+	arch := resolvedOpt.GetArch()
+	binDir := filepath.Join(Paths.OutputBinPath, "linux", arch)
+	toolsDir := filepath.Join(Paths.OutputBinToolPath, "linux", arch)
+	dockerDir := Paths.OutputDocker
+
+	services := make(map[string]int, len(serviceBinaries))
+	for binary, count := range serviceBinaries {
+		services[strings.TrimSuffix(binary, ".exe")] = count
+	}
+	tools := make([]string, 0, len(toolBinaries))
+	for _, tool := range toolBinaries {
+		tools = append(tools, strings.TrimSuffix(tool, ".exe"))
+	}
+	if len(services) == 0 && len(tools) == 0 {
+		return fmt.Errorf("no service or tool binaries configured in %s", StartConfigFile)
+	}
+
+	var missing []string
+	for name := range services {
+		if err := util.CheckExist(filepath.Join(binDir, name)); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range tools {
+		if err := util.CheckExist(filepath.Join(toolsDir, name)); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("linux/%s binaries not found: %s, build them first with PLATFORMS=linux_%s", arch, strings.Join(missing, ", "), arch)
+	}
+
+	if err := os.MkdirAll(dockerDir, 0755); err != nil {
+		return fmt.Errorf("failed to create docker directory %s: %v", dockerDir, err)
+	}
+
+	contextDir, err := dockerRelPath(dockerDir, Paths.Root)
+	if err != nil {
+		return err
+	}
+	configVolume, err := dockerRelPath(dockerDir, Paths.Config)
+	if err != nil {
+		return err
+	}
+	configVolume += ":" + dockerConfigMountPath + ":ro"
+
+	compose := composeFile{Services: make(map[string]*composeService)}
+	dependsOn := make(map[string]composeDependsOnOpt, len(tools))
+
+	for _, name := range tools {
+		dockerfile, err := writeDockerfile(dockerDir, name, toolsDir, resolvedOpt.GetBaseImage())
+		if err != nil {
+			return err
+		}
+		compose.Services[name] = &composeService{
+			Build:    composeBuild{Context: contextDir, Dockerfile: dockerfile},
+			Platform: "linux/" + arch,
+			Command:  instanceArgs(0),
+			Volumes:  []string{configVolume},
+			Restart:  "no",
+		}
+		dependsOn[name] = composeDependsOnOpt{Condition: "service_completed_successfully"}
+	}
+
+	for name, count := range services {
+		if slices.Contains(tools, name) {
+			return fmt.Errorf("binary %s is configured both as a service and as a tool", name)
+		}
+		dockerfile, err := writeDockerfile(dockerDir, name, binDir, resolvedOpt.GetBaseImage())
+		if err != nil {
+			return err
+		}
+		// Compose replicas share one command line, so each instance is a service of its own that is
+		// started with its index, like mage start does.
+		for i := 0; i < count; i++ {
+			instance := name
+			if count > 1 {
+				instance = fmt.Sprintf("%s-%d", name, i)
+			}
+			if _, exists := compose.Services[instance]; exists {
+				return fmt.Errorf("compose service %s of binary %s conflicts with another binary", instance, name)
+			}
+			service := &composeService{
+				Build:    composeBuild{Context: contextDir, Dockerfile: dockerfile},
+				Platform: "linux/" + arch,
+				Command:  instanceArgs(i),
+				Volumes:  []string{configVolume},
+				Restart:  "unless-stopped",
+			}
+			if len(dependsOn) > 0 {
+				service.DependsOn = dependsOn
+			}
+			compose.Services[instance] = service
+		}
+	}
+
+	var data bytes.Buffer
+	data.WriteString(dockerGeneratedHeader)
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&compose); err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", DockerComposeFile, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", DockerComposeFile, err)
+	}
+	composePath := filepath.Join(dockerDir, DockerComposeFile)
+	if err := os.WriteFile(composePath, data.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", composePath, err)
+	}
+
+	PrintGreen(fmt.Sprintf("Docker artifacts generated in %s", dockerDir))
+	PrintGreen(fmt.Sprintf("Run `docker compose -f %s up --build` to start the stack.", composePath))
+	return nil
+}
+
+// writeDockerfile writes <dockerDir>/<name>/Dockerfile and returns its path relative to the build context.
+func writeDockerfile(dockerDir, name, binDir, baseImage string) (string, error) {
+	binaryRel, err := dockerRelPath(Paths.Root, filepath.Join(binDir, name))
+	if err != nil {
+		return "", err
+	}
+	appBinary := dockerAppDir + "/" + name
+
+	var content strings.Builder
+	content.WriteString(dockerGeneratedHeader)
+	content.WriteString(fmt.Sprintf("FROM %s\n", baseImage))
+	content.WriteString(fmt.Sprintf("WORKDIR %s\n", dockerAppDir))
+	content.WriteString(fmt.Sprintf("COPY %s %s\n", binaryRel, appBinary))
+	content.WriteString(fmt.Sprintf("ENTRYPOINT [%q]\n", appBinary))
+
+	serviceDir := filepath.Join(dockerDir, name)
+	if err := os.MkdirAll(serviceDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %v", serviceDir, err)
+	}
+	dockerfilePath := filepath.Join(serviceDir, "Dockerfile")
+	if err := os.WriteFile(dockerfilePath, []byte(content.String()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", dockerfilePath, err)
+	}
+	PrintBlue(fmt.Sprintf("Generated %s", dockerfilePath))
+
+	return dockerRelPath(Paths.Root, dockerfilePath)
+}
+
+// instanceArgs returns the command of the compose service running one instance of a binary, the
+// Dockerfiles only set the entrypoint.
+func instanceArgs(index int) []string {
+	return []string{"-i", strconv.Itoa(index), "-c", dockerConfigMountPath}
+}
+
+func dockerRelPath(base, target string) (string, error) {
+	rel, err := filepath.Rel(filepath.Clean(base), filepath.Clean(target))
+	if err != nil {
+		return "", fmt.Errorf("failed to get relative path for %s: %v", target, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,70 @@
+package mageutil
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGenerateDockerArtifacts(t *testing.T) {
+	golden, err := filepath.Abs(filepath.Join("testdata", "docker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := useTempPaths(t)
+	config := "serviceBinaries:\n  openim-api: 2\n  openim-rpc-user: 1\ntoolBinaries:\n  - check-component\n"
+	if err := os.WriteFile(filepath.Join(root, StartConfigFile), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	binaries := []string{
+		filepath.Join(Paths.OutputBinPath, "linux", "arm64", "openim-api"),
+		filepath.Join(Paths.OutputBinPath, "linux", "arm64", "openim-rpc-user"),
+		filepath.Join(Paths.OutputBinToolPath, "linux", "arm64", "check-component"),
+	}
+	for _, binary := range binaries {
+		if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(binary, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	baseImage, arch := "alpine:3.20", "arm64"
+	if err := GenerateDockerArtifacts(&DockerOptions{BaseImage: &baseImage, Arch: &arch}); err != nil {
+		t.Fatalf("GenerateDockerArtifacts failed: %v", err)
+	}
+
+	files := map[string]string{
+		DockerComposeFile:            DockerComposeFile,
+		"openim-api.Dockerfile":      filepath.Join("openim-api", "Dockerfile"),
+		"openim-rpc-user.Dockerfile": filepath.Join("openim-rpc-user", "Dockerfile"),
+		"check-component.Dockerfile": filepath.Join("check-component", "Dockerfile"),
+	}
+	for name, path := range files {
+		got, err := os.ReadFile(filepath.Join(Paths.OutputDocker, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		goldenPath := filepath.Join(golden, name+".golden")
+		if *updateGolden {
+			if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s does not match %s:\n%s", path, goldenPath, got)
+		}
+	}
+}
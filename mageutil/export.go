@@ -17,6 +17,7 @@ import (
 type ExportOptions struct {
 	ProjectName *string
 	BuildOpt    *BuildOptions
+	Binaries    *[]string // Binary names, globs or @groups to build and export, default is all binaries
 }
 
 func (opt *ExportOptions) GetProjectName() string {
@@ -31,10 +32,18 @@ func (opt *ExportOptions) GetBuildOpt() *BuildOptions {
 	return util.NilAsZero(opt).BuildOpt
 }
 
+func (opt *ExportOptions) GetBinaries() []string {
+	return util.NilAsZero(util.NilAsZero(opt).Binaries)
+}
+
 func ExportMageLauncherArchived(overrideMappingPaths map[string]string, exportOpt *ExportOptions) error {
 	PrintBlue("Preparing launcher archive export...")
 	PrintBlue("Building binaries before export...")
-	Build(nil, nil, exportOpt.GetBuildOpt())
+	selected := exportOpt.GetBinaries()
+	results, err := Build(selected, nil, exportOpt.GetBuildOpt())
+	if err != nil {
+		return err
+	}
 
 	tmpDir := Paths.OutputTmp
 	exportDir := Paths.OutputExport
@@ -47,23 +56,23 @@ func ExportMageLauncherArchived(overrideMappingPaths map[string]string, exportOp
 		return fmt.Errorf("failed to create export directory %s: %v", exportDir, err)
 	}
 
-	platforms := os.Getenv("PLATFORMS")
-	if platforms == "" {
-		platforms = DetectPlatform()
+	platformList, err := ResolvePlatforms(resolveBuildOptionsWithEnv(exportOpt.GetBuildOpt()).GetPlatforms())
+	if err != nil {
+		return err
 	}
 
-	platformList := strings.Fields(platforms)
-	if len(platformList) == 0 {
-		return fmt.Errorf("no platforms specified for export")
+	var version *VersionInfo
+	if resolveBuildOptionsWithEnv(exportOpt.GetBuildOpt()).GetVersionStamp() {
+		var err error
+		version, err = ResolveVersionInfo(Paths.Root)
+		if err != nil {
+			return fmt.Errorf("failed to resolve version metadata: %w", err)
+		}
 	}
 
 	for _, platform := range platformList {
 		PrintBlue(fmt.Sprintf("Target platform: %s", platform))
-		platformParts := strings.SplitN(platform, "_", 2)
-		if len(platformParts) != 2 {
-			return fmt.Errorf("invalid platform format: %s", platform)
-		}
-		targetOS, targetArch := platformParts[0], platformParts[1]
+		targetOS, targetArch, _ := splitPlatform(platform)
 
 		mageBinaryPath := filepath.Join(tmpDir, fmt.Sprintf("mage_%s", platform))
 		if targetOS == "windows" {
@@ -72,6 +81,10 @@ func ExportMageLauncherArchived(overrideMappingPaths map[string]string, exportOp
 		PrintBlue(fmt.Sprintf("Compiling mage binary for %s: mage -compile %s", platform, mageBinaryPath))
 		cmd := exec.Command("mage", "-compile", mageBinaryPath, "-goos", targetOS, "-goarch", targetArch, "-ldflags", "-s -w")
 		cmd.Dir = Paths.Root
+		cmd.Env = os.Environ()
+		for k, v := range platformEnv(platform) {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
@@ -79,14 +92,38 @@ func ExportMageLauncherArchived(overrideMappingPaths map[string]string, exportOp
 		}
 		PrintGreen(fmt.Sprintf("Mage binary compiled: %s", mageBinaryPath))
 
-		mappingPaths, err := EnsureRootRelPaths(
-			filepath.Join(Paths.OutputBinPath, targetOS, targetArch),
-			filepath.Join(Paths.OutputBinToolPath, targetOS, targetArch),
-			filepath.Join(Paths.Root, StartConfigFile),
-		)
+		exportPaths := []string{filepath.Join(Paths.Root, StartConfigFile)}
+		if len(selected) > 0 {
+			// Only the selected binaries, the checksum files of the output directories list all of them.
+			for _, r := range results {
+				if !r.OK() || r.Platform != platform {
+					continue
+				}
+				exportPaths = append(exportPaths, r.Output)
+				if sbom := sbomPath(r); util.CheckExist(sbom) == nil {
+					exportPaths = append(exportPaths, sbom)
+				}
+			}
+		} else {
+			exportPaths = append(exportPaths,
+				filepath.Join(Paths.OutputBinPath, platformDir(platform)),
+				filepath.Join(Paths.OutputBinToolPath, platformDir(platform)),
+			)
+			if sbomDir := filepath.Join(Paths.OutputSBOM, platformDir(platform)); util.CheckExist(sbomDir) == nil {
+				exportPaths = append(exportPaths, sbomDir)
+			}
+		}
+		mappingPaths, err := EnsureRootRelPaths(exportPaths...)
 		if err != nil {
 			return err
 		}
+		// The archive holds a single platform, so a variant is stored under <goos>/<goarch> where the
+		// mage binary of the archive looks for the binaries.
+		if variantDir, archDir := filepath.ToSlash(platformDir(platform)), targetOS+"/"+targetArch; variantDir != archDir {
+			for in, out := range mappingPaths {
+				mappingPaths[in] = strings.TrimSuffix(strings.Replace(out+"/", "/"+variantDir+"/", "/"+archDir+"/", 1), "/")
+			}
+		}
 
 		mageInPath := mageBinaryPath
 		mageOutPath := "mage"
@@ -99,21 +136,30 @@ func ExportMageLauncherArchived(overrideMappingPaths map[string]string, exportOp
 			mappingPaths[k] = v
 		}
 
-		archiveName := exportArchiveBaseName(platform, exportOpt)
+		archiveName := exportArchiveBaseName(platform, exportOpt, version)
 		err = archive(filepath.Join(exportDir, archiveName), mappingPaths)
 		if err != nil {
 			return err
 		}
 	}
+
+	if err := WriteChecksums(exportDir, func(name string) bool { return strings.HasSuffix(name, ".tar.gz") }); err != nil {
+		return err
+	}
+	PrintGreen(fmt.Sprintf("Archive checksums written to %s", filepath.Join(exportDir, ChecksumsFile)))
 	return nil
 }
 
-func exportArchiveBaseName(platform string, exportOpt *ExportOptions) string {
-	projectName := exportOpt.GetProjectName()
-	if projectName == "" {
-		return fmt.Sprintf("exported_%s", platform)
+func exportArchiveBaseName(platform string, exportOpt *ExportOptions, version *VersionInfo) string {
+	parts := []string{"exported"}
+	if projectName := exportOpt.GetProjectName(); projectName != "" {
+		parts = append(parts, projectName)
+	}
+	if version != nil {
+		parts = append(parts, strings.NewReplacer("/", "_", "\\", "_").Replace(version.Label()))
 	}
-	return fmt.Sprintf("exported_%s_%s", projectName, platform)
+	parts = append(parts, platform)
+	return strings.Join(parts, "_")
 }
 
 func archive(archivePath string, mappingPaths map[string]string) error {
@@ -0,0 +1,56 @@
+package mageutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func runGoList(dir string, env map[string]string, args ...string) ([]byte, error) {
+	cmd := exec.Command("go", append([]string{"list"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("go list in %s failed: %s", dir, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("go list in %s failed: %v", dir, err)
+	}
+	return output, nil
+}
+
+// goListDeps runs `go list -deps -json` for the main package in pkgDir and returns the packages it
+// depends on, in dependency order and including the main package itself.
+func goListDeps(pkgDir string, env map[string]string, tags []string) ([]goListPackage, error) {
+	args := []string{"-deps", "-json"}
+	if len(tags) > 0 {
+		args = append(args, "-tags", strings.Join(tags, ","))
+	}
+	output, err := runGoList(pkgDir, env, append(args, ".")...)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []goListPackage
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var pkg goListPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("failed to decode go list output: %v", err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
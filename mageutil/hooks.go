@@ -0,0 +1,160 @@
+package mageutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+type HookPhase string
+
+const (
+	HookPreBuild  HookPhase = "preBuild"
+	HookPostBuild HookPhase = "postBuild"
+	HookPreStart  HookPhase = "preStart"
+	HookPostStart HookPhase = "postStart"
+	HookPreStop   HookPhase = "preStop"
+	HookPostStop  HookPhase = "postStop"
+)
+
+const DefaultHookTimeout = 5 * time.Minute
+
+// Hook is a shell command declared in start-config.yml that runs around a build, start or stop phase.
+type Hook struct {
+	Name     string            `yaml:"name"`
+	Command  string            `yaml:"command"`
+	Dir      string            `yaml:"dir"`      // Working directory, relative paths are resolved against the root directory
+	Env      map[string]string `yaml:"env"`      // Extra environment variables
+	Timeout  time.Duration     `yaml:"timeout"`  // Default is DefaultHookTimeout
+	Binaries []string          `yaml:"binaries"` // Only run when one of these binaries takes part in the phase, empty means global
+}
+
+type HooksConfig struct {
+	PreBuild  []Hook `yaml:"preBuild"`
+	PostBuild []Hook `yaml:"postBuild"`
+	PreStart  []Hook `yaml:"preStart"`
+	PostStart []Hook `yaml:"postStart"`
+	PreStop   []Hook `yaml:"preStop"`
+	PostStop  []Hook `yaml:"postStop"`
+}
+
+var hooksConfig HooksConfig
+
+func (c *HooksConfig) forPhase(phase HookPhase) []Hook {
+	switch phase {
+	case HookPreBuild:
+		return c.PreBuild
+	case HookPostBuild:
+		return c.PostBuild
+	case HookPreStart:
+		return c.PreStart
+	case HookPostStart:
+		return c.PostStart
+	case HookPreStop:
+		return c.PreStop
+	case HookPostStop:
+		return c.PostStop
+	default:
+		return nil
+	}
+}
+
+func (h *Hook) displayName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Command
+}
+
+// appliesTo reports whether the hook should run for a phase involving the given binaries.
+func (h *Hook) appliesTo(binaries []string) bool {
+	if len(h.Binaries) == 0 {
+		return true
+	}
+	for _, binary := range binaries {
+		if slices.Contains(h.Binaries, strings.TrimSuffix(binary, ".exe")) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunHooks runs the hooks configured for the phase in declaration order and stops at the first failure.
+// binaries are the names of the binaries taking part in the phase and select the per-binary hooks.
+func RunHooks(phase HookPhase, binaries []string) error {
+	hooks := hooksConfig.forPhase(phase)
+	for i := range hooks {
+		hook := &hooks[i]
+		if !hook.appliesTo(binaries) {
+			continue
+		}
+		if strings.TrimSpace(hook.Command) == "" {
+			return fmt.Errorf("%s hook %q has no command", phase, hook.displayName())
+		}
+
+		PrintBlue(fmt.Sprintf("Running %s hook %s ...", phase, hook.displayName()))
+		start := time.Now()
+		if err := runHook(phase, hook, binaries); err != nil {
+			return fmt.Errorf("%s hook %q failed, %s aborted: %w", phase, hook.displayName(), phase, err)
+		}
+		PrintGreen(fmt.Sprintf("%s hook %s finished in %s", phase, hook.displayName(), time.Since(start).Round(time.Millisecond)))
+	}
+	return nil
+}
+
+func runHook(phase HookPhase, hook *Hook, binaries []string) error {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, hook.Command)
+	// A timed out hook is killed with everything it started, not only the shell.
+	killOnCancel(cmd)
+	cmd.Dir = Paths.Root
+	if hook.Dir != "" {
+		cmd.Dir = hook.Dir
+		if !filepath.IsAbs(hook.Dir) {
+			cmd.Dir = filepath.Join(Paths.Root, hook.Dir)
+		}
+	}
+
+	names := make([]string, 0, len(binaries))
+	for _, binary := range binaries {
+		names = append(names, strings.TrimSuffix(binary, ".exe"))
+	}
+	cmd.Env = append(os.Environ(),
+		"GOMAKE_HOOK_PHASE="+string(phase),
+		"GOMAKE_BINARIES="+strings.Join(names, " "),
+		"GOMAKE_ROOT="+Paths.Root,
+		"GOMAKE_CONFIG_DIR="+Paths.Config,
+	)
+	for k, v := range hook.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// shellCommand runs command with the shell of the host, cmd on windows and sh elsewhere.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,104 @@
+package mageutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookAppliesTo(t *testing.T) {
+	tests := []struct {
+		hookBinaries []string
+		binaries     []string
+		want         bool
+	}{
+		{hookBinaries: nil, binaries: nil, want: true},
+		{hookBinaries: nil, binaries: []string{"openim-api"}, want: true},
+		{hookBinaries: []string{"openim-api"}, binaries: []string{"openim-api"}, want: true},
+		{hookBinaries: []string{"openim-api"}, binaries: []string{"openim-rpc-user", "openim-api.exe"}, want: true},
+		{hookBinaries: []string{"openim-api"}, binaries: []string{"openim-rpc-user"}, want: false},
+		{hookBinaries: []string{"openim-api"}, binaries: nil, want: false},
+	}
+	for _, tt := range tests {
+		hook := &Hook{Command: "true", Binaries: tt.hookBinaries}
+		if got := hook.appliesTo(tt.binaries); got != tt.want {
+			t.Errorf("hook for %q appliesTo(%q) = %v, want %v", tt.hookBinaries, tt.binaries, got, tt.want)
+		}
+	}
+}
+
+// useHooks replaces the configured hooks for the duration of a test.
+func useHooks(t *testing.T, config HooksConfig) {
+	t.Helper()
+	original := hooksConfig
+	t.Cleanup(func() { hooksConfig = original })
+	hooksConfig = config
+}
+
+func TestRunHooksStopsAtFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands use sh")
+	}
+	root := useTempPaths(t)
+	useHooks(t, HooksConfig{PreBuild: []Hook{
+		{Name: "first", Command: "touch first"},
+		{Name: "other-binary", Command: "touch other", Binaries: []string{"openim-rpc-user"}},
+		{Name: "failing", Command: "exit 3"},
+		{Name: "after", Command: "touch after"},
+	}})
+
+	err := RunHooks(HookPreBuild, []string{"openim-api"})
+	if err == nil || !strings.Contains(err.Error(), `preBuild hook "failing" failed`) {
+		t.Fatalf("RunHooks error = %v, want the failing hook reported", err)
+	}
+	for file, wantRun := range map[string]bool{"first": true, "other": false, "after": false} {
+		_, statErr := os.Stat(filepath.Join(root, file))
+		if ran := statErr == nil; ran != wantRun {
+			t.Errorf("hook creating %s ran = %v, want %v", file, ran, wantRun)
+		}
+	}
+}
+
+func TestRunHooksTimeoutKillsChildren(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks processes through /proc")
+	}
+	root := useTempPaths(t)
+	useHooks(t, HooksConfig{PreStart: []Hook{
+		{Name: "slow", Command: "sleep 30 & echo $! > child.pid; wait", Timeout: 200 * time.Millisecond},
+	}})
+
+	start := time.Now()
+	err := RunHooks(HookPreStart, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Fatalf("RunHooks error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > cancelWaitDelay {
+		t.Errorf("RunHooks returned after %s, the hook was not killed", elapsed)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "child.pid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The killed child may stay a zombie until it is reaped.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d started by the hook is still running", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,131 @@
+package mageutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	tempBinarySuffix = ".tmp"
+	oldBinarySuffix  = ".old"
+)
+
+// tempOutputPath returns the path go build writes a binary to before it replaces outputPath. It is in
+// the same directory, so the rename is atomic, and unique per process.
+func tempOutputPath(outputPath string) string {
+	dir, name := filepath.Split(outputPath)
+	return filepath.Join(dir, "."+name+"."+strconv.Itoa(os.Getpid())+tempBinarySuffix)
+}
+
+// isTempOutput reports whether name is a binary being written by a build or moved aside by one.
+func isTempOutput(name string) bool {
+	return strings.HasPrefix(name, ".") && (strings.HasSuffix(name, tempBinarySuffix) || strings.HasSuffix(name, oldBinarySuffix))
+}
+
+// replaceBinary atomically moves the binary at tmpPath to outputPath. A running service keeps
+// executing the file it was started from. Windows does not replace an executable that is running, so
+// it is moved aside first.
+func replaceBinary(tmpPath, outputPath string) error {
+	err := os.Rename(tmpPath, outputPath)
+	if err == nil || runtime.GOOS != "windows" {
+		return err
+	}
+	dir, name := filepath.Split(outputPath)
+	old := filepath.Join(dir, "."+name+oldBinarySuffix)
+	_ = os.Remove(old)
+	if moveErr := os.Rename(outputPath, old); moveErr != nil {
+		return err
+	}
+	return os.Rename(tmpPath, outputPath)
+}
+
+// startedHashPath returns the file recording the digest of the binary a service was last started from.
+func startedHashPath(binary string) string {
+	return filepath.Join(Paths.OutputTmp, "started", strings.TrimSuffix(binary, ".exe")+".sha256")
+}
+
+// recordStartedHash records the digest of the binary a service is started from, for platforms where
+// the executable of a running process cannot be read.
+func recordStartedHash(binary, binPath string) error {
+	sum, err := fileSHA256(binPath)
+	if err != nil {
+		return err
+	}
+	path := startedHashPath(binary)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(path), err)
+	}
+	return os.WriteFile(path, []byte(sum+"\n"), 0644)
+}
+
+// runningBinaryHash returns the digest of the executable a process is running. The file at the binary
+// path may have been replaced since the process started, so on linux the executable is read through
+// /proc, elsewhere the digest recorded when the service was started is used.
+func runningBinaryHash(binary, binPath string, pid int) (string, error) {
+	if runtime.GOOS == "linux" {
+		if sum, err := fileSHA256(filepath.Join("/proc", strconv.Itoa(pid), "exe")); err == nil {
+			return sum, nil
+		}
+	}
+	if data, err := os.ReadFile(startedHashPath(binary)); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	return fileSHA256(binPath)
+}
+
+// runningServiceHashes returns the digests of the executables of the running instances of every
+// service of start-config.yml started from the output directory of the host platform.
+func runningServiceHashes() (map[string][]string, error) {
+	pidMap, err := FindPIDsByBinaryPath()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string][]string)
+	for service := range serviceBinaries {
+		path := GetBinFullPath(service)
+		for _, pid := range pidMap[path] {
+			sum, err := runningBinaryHash(service, path, pid)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(hashes[service], sum) {
+				hashes[service] = append(hashes[service], sum)
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// changedRunningServices returns the running services with an instance executing another binary than
+// the one built.
+func changedRunningServices(results BuildResults, running map[string][]string) []string {
+	host := DetectPlatform()
+	var changed []string
+	for _, r := range results {
+		if r.Tool || r.Platform != host || !r.OK() {
+			continue
+		}
+		if sums, ok := running[r.Name]; ok && slices.ContainsFunc(sums, func(sum string) bool { return sum != r.SHA256 }) {
+			changed = append(changed, r.Name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// restartChangedServices restarts the running services whose binary changed in the build.
+func restartChangedServices(results BuildResults, running map[string][]string) error {
+	changed := changedRunningServices(results, running)
+	if len(changed) == 0 {
+		PrintGreen("No running service binary changed, nothing to restart")
+		return nil
+	}
+	PrintBlue(fmt.Sprintf("Restarting services with changed binaries: %s", strings.Join(changed, ", ")))
+	return restartServices(changed)
+}
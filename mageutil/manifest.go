@@ -0,0 +1,183 @@
+package mageutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BuildManifestFile    = "build-manifest.json"
+	buildManifestVersion = 1
+)
+
+// BuildManifest records how every binary was last built, so unchanged binaries can be skipped.
+type BuildManifest struct {
+	Version int                       `json:"version"`
+	Entries map[string]*ManifestEntry `json:"entries"`
+
+	path string
+	mu   sync.Mutex
+}
+
+type ManifestEntry struct {
+	Binary     string       `json:"binary"`     // Root-relative source path
+	Platform   string       `json:"platform"`   // Target platform, such as linux_amd64
+	Output     string       `json:"output"`     // Output path of the binary
+	InputsHash string       `json:"inputsHash"` // Hash of the package dependency inputs
+	Flags      []string     `json:"flags"`      // Build flags and environment affecting the output
+	GoVersion  string       `json:"goVersion"`
+	Version    *VersionInfo `json:"version,omitempty"` // Version metadata stamped into the binary
+	BuiltAt    time.Time    `json:"builtAt"`
+}
+
+func manifestKey(platform, binary string) string {
+	return platform + ":" + filepath.ToSlash(binary)
+}
+
+// LoadBuildManifest reads the build manifest from the output directory. A missing or unreadable
+// manifest yields an empty one, which simply causes every binary to be rebuilt.
+func LoadBuildManifest() *BuildManifest {
+	manifest := &BuildManifest{
+		Version: buildManifestVersion,
+		Entries: make(map[string]*ManifestEntry),
+		path:    filepath.Join(Paths.Output, BuildManifestFile),
+	}
+
+	data, err := os.ReadFile(manifest.path)
+	if err != nil {
+		return manifest
+	}
+	var stored BuildManifest
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != buildManifestVersion {
+		PrintYellow(fmt.Sprintf("Ignoring outdated or invalid build manifest %s", manifest.path))
+		return manifest
+	}
+	if stored.Entries != nil {
+		manifest.Entries = stored.Entries
+	}
+	return manifest
+}
+
+func (m *BuildManifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal build manifest: %v", err)
+	}
+	if err := os.WriteFile(m.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write build manifest %s: %v", m.path, err)
+	}
+	return nil
+}
+
+// UpToDate reports whether the binary was last built from the same inputs, flags and Go version
+// and its output still exists.
+func (m *BuildManifest) UpToDate(entry *ManifestEntry) bool {
+	m.mu.Lock()
+	prev, ok := m.Entries[manifestKey(entry.Platform, entry.Binary)]
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if prev.InputsHash != entry.InputsHash || prev.GoVersion != entry.GoVersion ||
+		prev.Output != entry.Output || !slices.Equal(prev.Flags, entry.Flags) {
+		return false
+	}
+	_, err := os.Stat(entry.Output)
+	return err == nil
+}
+
+func (m *BuildManifest) Record(entry *ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[manifestKey(entry.Platform, entry.Binary)] = entry
+}
+
+type goListPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Module     *goListModule
+	GoFiles    []string
+	CgoFiles   []string
+	CFiles     []string
+	CXXFiles   []string
+	HFiles     []string
+	SFiles     []string
+	SysoFiles  []string
+	EmbedFiles []string
+}
+
+type goListModule struct {
+	Path    string
+	Version string
+	Replace *goListModule
+}
+
+// hashPackageInputs hashes the inputs of the main package in pkgDir and all of its dependencies.
+// Standard packages are covered by the Go version and versioned modules by their module version,
+// everything else (main module, workspace and local replacements) by the content of its source files.
+func hashPackageInputs(pkgDir string, env map[string]string, tags []string) (string, error) {
+	pkgs, err := goListDeps(pkgDir, env, tags)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, pkg := range pkgs {
+		fmt.Fprintf(h, "package %s\n", pkg.ImportPath)
+		if pkg.Standard {
+			continue
+		}
+		if mod := pkg.Module; mod != nil && mod.Replace == nil && mod.Version != "" {
+			fmt.Fprintf(h, "module %s@%s\n", mod.Path, mod.Version)
+			continue
+		}
+
+		var files []string
+		for _, group := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.CXXFiles, pkg.HFiles, pkg.SFiles, pkg.SysoFiles, pkg.EmbedFiles} {
+			files = append(files, group...)
+		}
+		slices.Sort(files)
+		for _, file := range files {
+			if err := hashFileInto(h, filepath.Join(pkg.Dir, file), file); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFileInto(h io.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(h, "file %s\n", name)
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return nil
+}
+
+func resolveGoVersion() (string, error) {
+	output, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve go version: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
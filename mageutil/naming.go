@@ -0,0 +1,91 @@
+package mageutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NamingRule selects how the output name of a binary is derived from its source path.
+type NamingRule string
+
+const (
+	NamingBase NamingRule = "base" // Last element of the source path, cmd/chat/server -> server
+	NamingPath NamingRule = "path" // Source path below the cmd or tools directory joined with dashes, cmd/chat/server -> chat-server
+)
+
+func (r NamingRule) validate() error {
+	switch r {
+	case "", NamingBase, NamingPath:
+		return nil
+	default:
+		return fmt.Errorf("unknown naming rule %q in build.naming, expected %q or %q", r, NamingBase, NamingPath)
+	}
+}
+
+// binaryOutputName returns the output file name, without extension, of the binary at the root-relative
+// source path. Explicit names from build.names take precedence over the naming rule.
+func binaryOutputName(binary string) string {
+	if name, ok := buildConfig.Names[filepath.ToSlash(filepath.Clean(binary))]; ok && name != "" {
+		return name
+	}
+	if buildConfig.Naming == NamingPath {
+		rel := filepath.ToSlash(binarySourceRelPath(binary))
+		return strings.ReplaceAll(rel, "/", "-")
+	}
+	return filepath.Base(binary)
+}
+
+// binarySourceRelPath returns the source path of the binary relative to the cmd or tools directory
+// of its module.
+func binarySourceRelPath(binary string) string {
+	binary = moduleRelPath(binary)
+	for _, dir := range []string{normalizedSourcePrefix(Paths.ToolsDir), normalizedSourcePrefix(Paths.SrcDir)} {
+		if dir == "" {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, binary); err == nil && isSubPath(dir, binary) && rel != "." {
+			return rel
+		}
+	}
+	return binary
+}
+
+func isToolSource(binary string) bool {
+	tools := normalizedSourcePrefix(Paths.ToolsDir)
+	return tools != "" && isSubPath(tools, moduleRelPath(binary))
+}
+
+// checkOutputNameCollisions reports binaries that would be written to the same output file. Services
+// and tools are written to different directories, so they are checked separately.
+func checkOutputNameCollisions(binaries []string) error {
+	if err := buildConfig.Naming.validate(); err != nil {
+		return err
+	}
+
+	type key struct {
+		tool bool
+		name string
+	}
+	sources := make(map[key][]string)
+	for _, binary := range binaries {
+		k := key{tool: isToolSource(binary), name: binaryOutputName(binary)}
+		sources[k] = append(sources[k], filepath.ToSlash(binary))
+	}
+
+	var collisions []string
+	for k, paths := range sources {
+		if len(paths) < 2 {
+			continue
+		}
+		sort.Strings(paths)
+		collisions = append(collisions, fmt.Sprintf("  %s: %s", k.name, strings.Join(paths, ", ")))
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+	sort.Strings(collisions)
+	return fmt.Errorf("binaries with the same output name would overwrite each other:\n%s\nset build.naming to %q or add build.names entries in %s",
+		strings.Join(collisions, "\n"), NamingPath, StartConfigFile)
+}
@@ -19,6 +19,10 @@ const (
 	ToolsDir     = "tools"
 	TmpDir       = "tmp"
 	ExportDir    = "export"
+	DockerDir    = "docker"
+	ReportsDir   = "reports"
+	SBOMDir      = "sbom"
+	CoverageDir  = "coverage"
 	LogsDir      = "logs"
 	BinDir       = "bin"
 	PlatformsDir = "platforms"
@@ -33,6 +37,10 @@ type PathConfig struct {
 	OutputTools        string
 	OutputTmp          string
 	OutputExport       string
+	OutputDocker       string
+	OutputReports      string
+	OutputSBOM         string
+	OutputCoverage     string
 	OutputLogs         string
 	OutputBin          string
 	OutputBinPath      string
@@ -114,6 +122,10 @@ func NewPathConfig(opts *PathOptions) (*PathConfig, error) {
 	config.OutputTools = config.joinPath(config.Output, ToolsDir)
 	config.OutputTmp = config.joinPath(config.Output, TmpDir)
 	config.OutputExport = config.joinPath(config.Output, ExportDir)
+	config.OutputDocker = config.joinPath(config.Output, DockerDir)
+	config.OutputReports = config.joinPath(config.Output, ReportsDir)
+	config.OutputSBOM = config.joinPath(config.Output, SBOMDir)
+	config.OutputCoverage = config.joinPath(config.Output, CoverageDir)
 	config.OutputLogs = config.joinPath(config.Output, LogsDir)
 	config.OutputBin = config.joinPath(config.Output, BinDir)
 
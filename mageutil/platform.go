@@ -0,0 +1,195 @@
+package mageutil
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// platformAll is the wildcard of the platform shorthands, such as linux/all or all/arm64.
+const platformAll = "all"
+
+// platformVariant is the environment variable selecting the microarchitecture level of a GOARCH,
+// given as the third component of a platform such as linux_amd64_v3 or linux_arm_7.
+type platformVariant struct {
+	Env    string
+	Values []string
+}
+
+var platformVariants = map[string]platformVariant{
+	"386":      {Env: "GO386", Values: []string{"sse2", "softfloat"}},
+	"amd64":    {Env: "GOAMD64", Values: []string{"v1", "v2", "v3", "v4"}},
+	"arm":      {Env: "GOARM", Values: []string{"5", "6", "7"}},
+	"arm64":    {Env: "GOARM64", Values: []string{"v8.0", "v8.1", "v8.2", "v8.3", "v8.4", "v8.5", "v8.6", "v8.7", "v8.8", "v8.9", "v9.0", "v9.1", "v9.2", "v9.3", "v9.4", "v9.5"}},
+	"mips":     {Env: "GOMIPS", Values: []string{"hardfloat", "softfloat"}},
+	"mipsle":   {Env: "GOMIPS", Values: []string{"hardfloat", "softfloat"}},
+	"mips64":   {Env: "GOMIPS64", Values: []string{"hardfloat", "softfloat"}},
+	"mips64le": {Env: "GOMIPS64", Values: []string{"hardfloat", "softfloat"}},
+	"ppc64":    {Env: "GOPPC64", Values: []string{"power8", "power9", "power10"}},
+	"ppc64le":  {Env: "GOPPC64", Values: []string{"power8", "power9", "power10"}},
+}
+
+var (
+	distListOnce      sync.Once
+	distListPlatforms []string
+	distListErr       error
+)
+
+// supportedPlatforms returns the platforms of the Go toolchain, as listed by `go tool dist list`,
+// in goos_goarch form. The list is read once per process.
+func supportedPlatforms() ([]string, error) {
+	distListOnce.Do(func() {
+		cmd := exec.Command("go", "tool", "dist", "list")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			distListErr = fmt.Errorf("go tool dist list failed: %v %s", err, strings.TrimSpace(stderr.String()))
+			return
+		}
+		for _, line := range strings.Fields(string(output)) {
+			if goos, goarch, ok := strings.Cut(line, "/"); ok {
+				distListPlatforms = append(distListPlatforms, goos+"_"+goarch)
+			}
+		}
+	})
+	return distListPlatforms, distListErr
+}
+
+// splitPlatform splits a platform in goos_goarch[_variant] form. Platforms are validated by
+// ResolvePlatforms before a build starts.
+func splitPlatform(platform string) (goos, goarch, variant string) {
+	goos, goarch, _ = strings.Cut(platform, "_")
+	goarch, variant, _ = strings.Cut(goarch, "_")
+	return goos, goarch, variant
+}
+
+// basePlatform returns the platform without its microarchitecture variant.
+func basePlatform(platform string) string {
+	goos, goarch, _ := splitPlatform(platform)
+	return goos + "_" + goarch
+}
+
+// platformDir returns the directory of the platform below an output directory, <goos>/<goarch> or
+// <goos>/<goarch>_<variant>, so variants do not replace each other.
+func platformDir(platform string) string {
+	goos, goarch, variant := splitPlatform(platform)
+	if variant != "" {
+		goarch += "_" + variant
+	}
+	return filepath.Join(goos, goarch)
+}
+
+// platformEnv returns the environment variables selecting the platform in go build.
+func platformEnv(platform string) map[string]string {
+	goos, goarch, variant := splitPlatform(platform)
+	env := map[string]string{"GOOS": goos, "GOARCH": goarch}
+	if variant != "" {
+		env[platformVariants[goarch].Env] = variant
+	}
+	return env
+}
+
+// ResolvePlatforms validates platform entries against the Go toolchain and returns them in
+// goos_goarch[_variant] form, without duplicates. An entry is goos_goarch or goos/goarch, optionally
+// followed by a microarchitecture level such as linux_amd64_v3 or linux/arm/7. goos/all and
+// all/goarch select every supported architecture of an OS or every OS of an architecture.
+// No entries means the host platform.
+func ResolvePlatforms(entries []string) ([]string, error) {
+	if len(entries) == 0 {
+		return []string{DetectPlatform()}, nil
+	}
+	supported, err := supportedPlatforms()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supported platforms: %w", err)
+	}
+
+	var resolved []string
+	add := func(platform string) {
+		if !slices.Contains(resolved, platform) {
+			resolved = append(resolved, platform)
+		}
+	}
+	for _, entry := range entries {
+		parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(entry), "/", "_"), "_")
+		if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+			return nil, fmt.Errorf("invalid platform %q, expected goos_goarch such as linux_amd64, or a shorthand such as linux/all", entry)
+		}
+		goos, goarch := parts[0], parts[1]
+		if goos == platformAll && goarch == platformAll {
+			return nil, fmt.Errorf("invalid platform %q, give an OS or an architecture", entry)
+		}
+
+		matched, err := matchPlatforms(supported, goos, goarch)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported platform %q: %w", entry, err)
+		}
+		var variant string
+		if len(parts) == 3 {
+			variant = parts[2]
+			if err := checkPlatformVariant(goarch, variant); err != nil {
+				return nil, fmt.Errorf("unsupported platform %q: %w", entry, err)
+			}
+			variant = "_" + variant
+		}
+		for _, platform := range matched {
+			add(platform + variant)
+		}
+	}
+	return resolved, nil
+}
+
+// matchPlatforms returns the supported platforms of goos and goarch, either of which may be "all".
+func matchPlatforms(supported []string, goos, goarch string) ([]string, error) {
+	var oses, arches []string
+	var matched []string
+	for _, platform := range supported {
+		pOS, pArch, _ := splitPlatform(platform)
+		oses, arches = append(oses, pOS), append(arches, pArch)
+		if (goos == platformAll || goos == pOS) && (goarch == platformAll || goarch == pArch) {
+			matched = append(matched, platform)
+		}
+	}
+	if len(matched) > 0 {
+		return matched, nil
+	}
+
+	oses, arches = uniqueSorted(oses), uniqueSorted(arches)
+	if goos != platformAll && !slices.Contains(oses, goos) {
+		return nil, fmt.Errorf("unknown GOOS %q%s", goos, didYouMean(goos, oses))
+	}
+	if goarch != platformAll && !slices.Contains(arches, goarch) {
+		return nil, fmt.Errorf("unknown GOARCH %q%s", goarch, didYouMean(goarch, arches))
+	}
+	var osArches []string
+	for _, platform := range supported {
+		if pOS, pArch, _ := splitPlatform(platform); pOS == goos {
+			osArches = append(osArches, pArch)
+		}
+	}
+	return nil, fmt.Errorf("GOARCH %q is not supported on %s, supported: %s", goarch, goos, strings.Join(osArches, ", "))
+}
+
+func checkPlatformVariant(goarch, variant string) error {
+	if goarch == platformAll {
+		return fmt.Errorf("a microarchitecture level needs an architecture")
+	}
+	v, ok := platformVariants[goarch]
+	if !ok {
+		return fmt.Errorf("GOARCH %q has no microarchitecture levels", goarch)
+	}
+	if !slices.Contains(v.Values, variant) {
+		return fmt.Errorf("unknown %s %q, supported: %s", v.Env, variant, strings.Join(v.Values, ", "))
+	}
+	return nil
+}
+
+func uniqueSorted(values []string) []string {
+	sort.Strings(values)
+	return slices.Compact(values)
+}
@@ -0,0 +1,64 @@
+package mageutil
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useSupportedPlatforms replaces the platforms of `go tool dist list` for the duration of a test.
+func useSupportedPlatforms(t *testing.T, platforms []string) {
+	t.Helper()
+	original, originalErr := supportedPlatforms()
+	distListPlatforms, distListErr = platforms, nil
+	t.Cleanup(func() { distListPlatforms, distListErr = original, originalErr })
+}
+
+func TestResolvePlatforms(t *testing.T) {
+	useSupportedPlatforms(t, []string{
+		"darwin_amd64", "darwin_arm64", "freebsd_386", "linux_amd64", "linux_arm", "linux_arm64", "windows_amd64", "windows_arm64",
+	})
+
+	tests := []struct {
+		entries []string
+		want    []string
+		wantErr string
+	}{
+		{entries: nil, want: []string{DetectPlatform()}},
+		{entries: []string{"linux_amd64"}, want: []string{"linux_amd64"}},
+		{entries: []string{"linux/arm64"}, want: []string{"linux_arm64"}},
+		{entries: []string{" darwin/arm64 "}, want: []string{"darwin_arm64"}},
+		{entries: []string{"linux/all"}, want: []string{"linux_amd64", "linux_arm", "linux_arm64"}},
+		{entries: []string{"all/arm64"}, want: []string{"darwin_arm64", "linux_arm64", "windows_arm64"}},
+		{entries: []string{"all_amd64_v3"}, want: []string{"darwin_amd64_v3", "linux_amd64_v3", "windows_amd64_v3"}},
+		{entries: []string{"linux_amd64_v3", "linux/arm/7"}, want: []string{"linux_amd64_v3", "linux_arm_7"}},
+		{entries: []string{"linux_amd64", "linux/amd64", "linux/all"}, want: []string{"linux_amd64", "linux_arm", "linux_arm64"}},
+		{entries: []string{"linux"}, wantErr: "invalid platform"},
+		{entries: []string{"linux__amd64"}, wantErr: "invalid platform"},
+		{entries: []string{"linux_amd64_v3_x"}, wantErr: "invalid platform"},
+		{entries: []string{"all/all"}, wantErr: "give an OS or an architecture"},
+		{entries: []string{"linx_amd64"}, wantErr: `unknown GOOS "linx", did you mean "linux"?`},
+		{entries: []string{"linux/amd46"}, wantErr: `unknown GOARCH "amd46", did you mean "amd64"?`},
+		{entries: []string{"plan9_amd64"}, wantErr: `unknown GOOS "plan9"`},
+		{entries: []string{"freebsd_arm64"}, wantErr: `GOARCH "arm64" is not supported on freebsd, supported: 386`},
+		{entries: []string{"linux_amd64_v5"}, wantErr: `unknown GOAMD64 "v5", supported: v1, v2, v3, v4`},
+		{entries: []string{"freebsd_386_v1"}, wantErr: `unknown GO386 "v1"`},
+		{entries: []string{"linux/all/v3"}, wantErr: "a microarchitecture level needs an architecture"},
+	}
+	for _, tt := range tests {
+		got, err := ResolvePlatforms(tt.entries)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolvePlatforms(%q) error = %v, want one containing %q", tt.entries, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolvePlatforms(%q) failed: %v", tt.entries, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ResolvePlatforms(%q) = %q, want %q", tt.entries, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package mageutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PostBuildFailurePolicy decides what a failing post-build step does to the binary.
+type PostBuildFailurePolicy string
+
+const (
+	PostBuildFail   PostBuildFailurePolicy = "fail"   // The binary fails to build and its remaining steps are skipped, the default
+	PostBuildWarn   PostBuildFailurePolicy = "warn"   // A warning is printed and the pipeline continues
+	PostBuildIgnore PostBuildFailurePolicy = "ignore" // The pipeline continues, the failure only shows in the results
+)
+
+const DefaultPostBuildStepTimeout = 10 * time.Minute
+
+// PostBuildStepConfig is one step of the post-build pipeline declared in build.postBuild of
+// start-config.yml. Steps run in declaration order on every binary they apply to.
+type PostBuildStepConfig struct {
+	Step      string                 `yaml:"step" json:"step"`                     // Kind of step: strip, upx, command, checksum, copy or a registered one
+	Name      string                 `yaml:"name" json:"name,omitempty"`           // Display name, default is the kind
+	Platforms []string               `yaml:"platforms" json:"platforms,omitempty"` // Platforms or globs such as darwin_*, empty means all
+	Binaries  []string               `yaml:"binaries" json:"binaries,omitempty"`   // Binary names or globs, empty means all
+	OnFailure PostBuildFailurePolicy `yaml:"onFailure" json:"onFailure,omitempty"` // Default is PostBuildFail
+	Command   string                 `yaml:"command" json:"command,omitempty"`     // Tool of strip and upx, shell command of command
+	Args      []string               `yaml:"args" json:"args,omitempty"`           // Arguments passed before the binary path to strip and upx
+	Dest      string                 `yaml:"dest" json:"dest,omitempty"`           // Destination directory of copy and checksum, may use {platform}, {os}, {arch}, {variant} and {name}
+	Algorithm string                 `yaml:"algorithm" json:"algorithm,omitempty"` // Digest of checksum, sha256 or sha512, default is sha256
+	Env       map[string]string      `yaml:"env" json:"env,omitempty"`             // Extra environment variables of commands
+	With      map[string]string      `yaml:"with" json:"with,omitempty"`           // Parameters of registered steps
+	Timeout   time.Duration          `yaml:"timeout" json:"timeout,omitempty"`     // Default is DefaultPostBuildStepTimeout
+}
+
+func (c *PostBuildStepConfig) displayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Step
+}
+
+func (c *PostBuildStepConfig) policy() PostBuildFailurePolicy {
+	if c.OnFailure == "" {
+		return PostBuildFail
+	}
+	return c.OnFailure
+}
+
+// signature identifies the configuration of the step in the build manifest, so changing the
+// pipeline rebuilds the binaries it applies to.
+func (c *PostBuildStepConfig) signature() string {
+	data, _ := json.Marshal(c)
+	return "post-build " + string(data)
+}
+
+// appliesTo reports whether the step runs for the binary output name on the platform.
+func (c *PostBuildStepConfig) appliesTo(name, platform string) bool {
+	return matchesAny(c.Platforms, platform) && matchesAny(c.Binaries, strings.TrimSuffix(name, ".exe"))
+}
+
+// matchesAny reports whether value matches one of the globs, true when there are none.
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PostBuildArtifact is the binary a post-build step processes.
+type PostBuildArtifact struct {
+	Binary   string // Root-relative source path
+	Name     string // Output file name, with .exe on windows
+	Platform string
+	Path     string // Path of the built binary, a temporary file until every step passed
+}
+
+// PostBuildStep processes a built binary. Output written to out is kept in the build results.
+type PostBuildStep interface {
+	Run(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error
+}
+
+// PostBuildStepFunc adapts a function to the PostBuildStep interface.
+type PostBuildStepFunc func(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error
+
+func (f PostBuildStepFunc) Run(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error {
+	return f(ctx, artifact, config, out)
+}
+
+var (
+	postBuildStepsMu sync.RWMutex
+	postBuildSteps   = map[string]PostBuildStep{
+		"strip":    PostBuildStepFunc(runStripStep),
+		"upx":      PostBuildStepFunc(runUPXStep),
+		"command":  PostBuildStepFunc(runCommandStep),
+		"checksum": PostBuildStepFunc(runChecksumStep),
+		"copy":     PostBuildStepFunc(runCopyStep),
+	}
+)
+
+// RegisterPostBuildStep makes a step kind available to build.postBuild, typically from an init
+// function of the magefile. Registering an existing kind replaces it.
+func RegisterPostBuildStep(kind string, step PostBuildStep) {
+	postBuildStepsMu.Lock()
+	defer postBuildStepsMu.Unlock()
+	postBuildSteps[kind] = step
+}
+
+func lookupPostBuildStep(kind string) (PostBuildStep, bool) {
+	postBuildStepsMu.RLock()
+	defer postBuildStepsMu.RUnlock()
+	step, ok := postBuildSteps[kind]
+	return step, ok
+}
+
+// validatePostBuildSteps checks the configured pipeline before anything is compiled.
+func validatePostBuildSteps() error {
+	for i, step := range buildConfig.PostBuild {
+		where := fmt.Sprintf("build.postBuild[%d] (%s)", i, step.displayName())
+		if _, ok := lookupPostBuildStep(step.Step); !ok {
+			return fmt.Errorf("%s: unknown step %q", where, step.Step)
+		}
+		switch step.policy() {
+		case PostBuildFail, PostBuildWarn, PostBuildIgnore:
+		default:
+			return fmt.Errorf("%s: invalid onFailure %q, expected fail, warn or ignore", where, step.OnFailure)
+		}
+		switch {
+		case step.Step == "command" && strings.TrimSpace(step.Command) == "":
+			return fmt.Errorf("%s: command is required", where)
+		case step.Step == "copy" && strings.TrimSpace(step.Dest) == "":
+			return fmt.Errorf("%s: dest is required", where)
+		case step.Step == "checksum" && checksumHash(step.Algorithm) == nil:
+			return fmt.Errorf("%s: unsupported algorithm %q, expected sha256 or sha512", where, step.Algorithm)
+		}
+		for _, pattern := range append(slices.Clone(step.Platforms), step.Binaries...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: invalid pattern %q: %v", where, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// postBuildStepsFor returns the steps to run on a binary. The compress option adds a UPX step
+// in front of the configured ones whose failure only warns, unless the pipeline has its own.
+func postBuildStepsFor(name, platform string, compress bool) []*PostBuildStepConfig {
+	var steps []*PostBuildStepConfig
+	var hasUPX bool
+	for i := range buildConfig.PostBuild {
+		step := &buildConfig.PostBuild[i]
+		if step.appliesTo(name, platform) {
+			steps = append(steps, step)
+			hasUPX = hasUPX || step.Step == "upx"
+		}
+	}
+	if compress && !hasUPX {
+		steps = append([]*PostBuildStepConfig{{Step: "upx", OnFailure: PostBuildWarn}}, steps...)
+	}
+	return steps
+}
+
+type PostBuildStepStatus string
+
+const (
+	PostBuildStepSucceeded PostBuildStepStatus = "ok"
+	PostBuildStepFailed    PostBuildStepStatus = "failed"
+	PostBuildStepSkipped   PostBuildStepStatus = "skipped" // Not run because an earlier step failed the binary
+)
+
+// PostBuildStepResult is the outcome of one post-build step on one binary.
+type PostBuildStepResult struct {
+	Name     string
+	Step     string
+	Policy   PostBuildFailurePolicy
+	Status   PostBuildStepStatus
+	Duration time.Duration
+	Output   string
+	Err      error
+}
+
+// runPostBuildSteps runs the steps in order. It returns the result of every step and the error of
+// the first step whose failure policy fails the binary. Steps are skipped once ctx is done.
+func runPostBuildSteps(ctx context.Context, artifact *PostBuildArtifact, steps []*PostBuildStepConfig) ([]*PostBuildStepResult, error) {
+	results := make([]*PostBuildStepResult, 0, len(steps))
+	var failErr error
+	for _, step := range steps {
+		result := &PostBuildStepResult{Name: step.displayName(), Step: step.Step, Policy: step.policy()}
+		results = append(results, result)
+		if failErr != nil {
+			result.Status = PostBuildStepSkipped
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			result.Status = PostBuildStepSkipped
+			failErr = err
+			continue
+		}
+
+		PrintBlue(fmt.Sprintf("Running post-build step %s on %s for %s ...", result.Name, artifact.Name, artifact.Platform))
+		start := time.Now()
+		output, err := runPostBuildStep(ctx, artifact, step)
+		result.Duration, result.Output = time.Since(start), output
+		if err == nil {
+			result.Status = PostBuildStepSucceeded
+			continue
+		}
+
+		// Failures are reported by the build summary, warn and ignore only differ there.
+		result.Status, result.Err = PostBuildStepFailed, err
+		if result.Policy == PostBuildFail {
+			failErr = fmt.Errorf("post-build step %s failed on %s for %s: %w", result.Name, artifact.Name, artifact.Platform, err)
+		}
+	}
+	return results, failErr
+}
+
+func runPostBuildStep(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig) (string, error) {
+	step, ok := lookupPostBuildStep(config.Step)
+	if !ok {
+		return "", fmt.Errorf("unknown step %q", config.Step)
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPostBuildStepTimeout
+	}
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The output is captured per binary so concurrent builds do not interleave it.
+	var output bytes.Buffer
+	err := step.Run(stepCtx, artifact, config, &output)
+	if ctx.Err() == nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return output.String(), err
+}
+
+// runTool runs a tool with the configured arguments followed by the binary path.
+func runTool(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer, tool string, defaultArgs []string) error {
+	if config.Command != "" {
+		tool = os.ExpandEnv(config.Command)
+	}
+	args := defaultArgs
+	if len(config.Args) > 0 {
+		args = config.Args
+	}
+	fields := strings.Fields(tool)
+	if len(fields) == 0 {
+		return fmt.Errorf("no tool configured")
+	}
+	cmd := exec.CommandContext(ctx, fields[0], append(append(fields[1:], args...), artifact.Path)...)
+	interruptOnCancel(cmd)
+	cmd.Env = postBuildEnv(artifact, config)
+	cmd.Stdout, cmd.Stderr = out, out
+	return cmd.Run()
+}
+
+func runStripStep(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error {
+	return runTool(ctx, artifact, config, out, "strip", nil)
+}
+
+func runUPXStep(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error {
+	return runTool(ctx, artifact, config, out, "upx", []string{"--lzma"})
+}
+
+// runCommandStep runs a shell command, such as a codesign invocation, with the artifact in the
+// GOMAKE_ARTIFACT environment variable.
+func runCommandStep(ctx context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error {
+	cmd := shellCommand(ctx, config.Command)
+	interruptOnCancel(cmd)
+	cmd.Dir = Paths.Root
+	cmd.Env = postBuildEnv(artifact, config)
+	cmd.Stdout, cmd.Stderr = out, out
+	return cmd.Run()
+}
+
+// runChecksumStep writes a <binary>.<algorithm> file in the format of sha256sum next to the binary,
+// or into dest.
+func runChecksumStep(_ context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error {
+	algorithm := strings.ToLower(config.Algorithm)
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+	h := checksumHash(algorithm)
+	if h == nil {
+		return fmt.Errorf("unsupported algorithm %q", config.Algorithm)
+	}
+	f, err := os.Open(artifact.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to read %s: %v", artifact.Path, err)
+	}
+
+	dir := filepath.Dir(artifact.Path)
+	if config.Dest != "" {
+		dir = expandPostBuildDest(config.Dest, artifact)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	sumPath := filepath.Join(dir, artifact.Name+"."+algorithm)
+	if err := os.WriteFile(sumPath, []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(h.Sum(nil)), artifact.Name)), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "wrote %s\n", sumPath)
+	return nil
+}
+
+func checksumHash(algorithm string) hash.Hash {
+	switch strings.ToLower(algorithm) {
+	case "", "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// runCopyStep copies the binary into the dest directory.
+func runCopyStep(_ context.Context, artifact *PostBuildArtifact, config *PostBuildStepConfig, out io.Writer) error {
+	dir := expandPostBuildDest(config.Dest, artifact)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	dest := filepath.Join(dir, artifact.Name)
+	if err := copyFile(artifact.Path, dest); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "copied to %s\n", dest)
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	outFile, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(outFile, in); err != nil {
+		outFile.Close()
+		return err
+	}
+	return outFile.Close()
+}
+
+// expandPostBuildDest expands the placeholders and environment variables of a destination
+// directory, relative paths are resolved against the root directory.
+func expandPostBuildDest(dest string, artifact *PostBuildArtifact) string {
+	goos, goarch, variant := splitPlatform(artifact.Platform)
+	dest = strings.NewReplacer(
+		"{platform}", artifact.Platform,
+		"{os}", goos,
+		"{arch}", goarch,
+		"{variant}", variant,
+		"{name}", strings.TrimSuffix(artifact.Name, ".exe"),
+	).Replace(os.ExpandEnv(dest))
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(Paths.Root, dest)
+	}
+	return dest
+}
+
+func postBuildEnv(artifact *PostBuildArtifact, config *PostBuildStepConfig) []string {
+	goos, goarch, variant := splitPlatform(artifact.Platform)
+	env := append(os.Environ(),
+		"GOMAKE_ARTIFACT="+artifact.Path,
+		"GOMAKE_BINARY="+strings.TrimSuffix(artifact.Name, ".exe"),
+		"GOMAKE_PLATFORM="+artifact.Platform,
+		"GOMAKE_OS="+goos,
+		"GOMAKE_ARCH="+goarch,
+		"GOMAKE_VARIANT="+variant,
+		"GOMAKE_ROOT="+Paths.Root,
+	)
+	for k, v := range config.Env {
+		env = append(env, k+"="+os.ExpandEnv(v))
+	}
+	return env
+}
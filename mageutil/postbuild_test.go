@@ -0,0 +1,122 @@
+package mageutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandPostBuildDest(t *testing.T) {
+	tests := []struct {
+		dest     string
+		platform string
+		name     string
+		want     string
+	}{
+		{dest: "/srv/{platform}/{name}", platform: "linux_amd64", name: "openim-api", want: "/srv/linux_amd64/openim-api"},
+		{dest: "/srv/{os}/{arch}/{name}", platform: "linux_amd64", name: "openim-api", want: "/srv/linux/amd64/openim-api"},
+		{dest: "/srv/{os}/{arch}/{variant}", platform: "linux_amd64_v3", name: "openim-api", want: "/srv/linux/amd64/v3"},
+		{dest: "/srv/{os}/{arch}/{name}", platform: "windows_arm64", name: "openim-api.exe", want: "/srv/windows/arm64/openim-api"},
+	}
+	for _, tt := range tests {
+		artifact := &PostBuildArtifact{Name: tt.name, Platform: tt.platform}
+		if got := expandPostBuildDest(tt.dest, artifact); got != tt.want {
+			t.Errorf("expandPostBuildDest(%s, %s) = %s, want %s", tt.dest, tt.platform, got, tt.want)
+		}
+	}
+}
+
+func TestPostBuildEnvVariant(t *testing.T) {
+	artifact := &PostBuildArtifact{Name: "openim-api", Platform: "linux_arm_7", Path: "/tmp/openim-api"}
+	env := postBuildEnv(artifact, &PostBuildStepConfig{})
+	for _, want := range []string{"GOMAKE_PLATFORM=linux_arm_7", "GOMAKE_OS=linux", "GOMAKE_ARCH=arm", "GOMAKE_VARIANT=7"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("postBuildEnv is missing %s", want)
+		}
+	}
+}
+
+// registerTestStep registers a post-build step kind for the duration of a test.
+func registerTestStep(t *testing.T, kind string, step PostBuildStepFunc) {
+	t.Helper()
+	RegisterPostBuildStep(kind, step)
+	t.Cleanup(func() {
+		postBuildStepsMu.Lock()
+		defer postBuildStepsMu.Unlock()
+		delete(postBuildSteps, kind)
+	})
+}
+
+func TestRunPostBuildStepsFailurePolicy(t *testing.T) {
+	registerTestStep(t, "test-fail", func(context.Context, *PostBuildArtifact, *PostBuildStepConfig, io.Writer) error {
+		return errors.New("broken")
+	})
+	registerTestStep(t, "test-ok", func(context.Context, *PostBuildArtifact, *PostBuildStepConfig, io.Writer) error {
+		return nil
+	})
+
+	tests := []struct {
+		policy   PostBuildFailurePolicy
+		wantErr  bool
+		statuses []PostBuildStepStatus
+	}{
+		{policy: "", wantErr: true, statuses: []PostBuildStepStatus{PostBuildStepFailed, PostBuildStepSkipped}},
+		{policy: PostBuildFail, wantErr: true, statuses: []PostBuildStepStatus{PostBuildStepFailed, PostBuildStepSkipped}},
+		{policy: PostBuildWarn, statuses: []PostBuildStepStatus{PostBuildStepFailed, PostBuildStepSucceeded}},
+		{policy: PostBuildIgnore, statuses: []PostBuildStepStatus{PostBuildStepFailed, PostBuildStepSucceeded}},
+	}
+	for _, tt := range tests {
+		artifact := &PostBuildArtifact{Binary: "cmd/openim-api", Name: "openim-api", Platform: "linux_amd64"}
+		steps := []*PostBuildStepConfig{{Step: "test-fail", OnFailure: tt.policy}, {Step: "test-ok"}}
+		results, err := runPostBuildSteps(context.Background(), artifact, steps)
+		if gotErr := err != nil; gotErr != tt.wantErr {
+			t.Errorf("policy %q: error = %v, want error %v", tt.policy, err, tt.wantErr)
+		}
+		var statuses []PostBuildStepStatus
+		for _, r := range results {
+			statuses = append(statuses, r.Status)
+		}
+		if !slices.Equal(statuses, tt.statuses) {
+			t.Errorf("policy %q: statuses = %q, want %q", tt.policy, statuses, tt.statuses)
+		}
+		if results[0].Err == nil || results[0].Policy != steps[0].policy() {
+			t.Errorf("policy %q: failed step result = %+v", tt.policy, results[0])
+		}
+	}
+}
+
+func TestChecksumStepFilesNotInChecksums(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "openim-api")
+	if err := os.WriteFile(binary, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	artifact := &PostBuildArtifact{Binary: "cmd/openim-api", Name: "openim-api", Platform: "linux_amd64", Path: binary}
+	for _, algorithm := range []string{"sha256", "sha512"} {
+		if err := runChecksumStep(context.Background(), artifact, &PostBuildStepConfig{Step: "checksum", Algorithm: algorithm}, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := BuildResults{{Binary: "cmd/openim-api", Name: "openim-api", Platform: "linux_amd64", Status: BuildSucceeded, Output: binary}}
+	for i := 0; i < 2; i++ {
+		if err := writeBuildChecksums(results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ChecksumsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := results[0].SHA256 + "  openim-api\n"; string(data) != want {
+		t.Errorf("%s = %q, want %q", ChecksumsFile, data, want)
+	}
+	if strings.Contains(string(data), ".sha") {
+		t.Errorf("%s lists checksum files: %s", ChecksumsFile, data)
+	}
+}
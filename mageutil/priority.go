@@ -1,9 +1,12 @@
 package mageutil
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"time"
 )
 
 type PriorityLevel int
@@ -15,23 +18,76 @@ const (
 	PriorityHigh
 )
 
-func RunWithPriority(priority PriorityLevel, env map[string]string, cmd string, args ...string) error {
-	execCmd := exec.Command(cmd, args...)
-	execCmd.Env = os.Environ()
-	for k, v := range env {
+// cancelWaitDelay is how long a cancelled command may take to exit after being interrupted before it
+// is killed. It is shorter than the cleanup time mage gives a target after Ctrl-C.
+const cancelWaitDelay = 3 * time.Second
+
+// RunOptions configures a command started by RunWithOptions.
+type RunOptions struct {
+	Priority PriorityLevel
+	Dir      string            // Working directory of the command, default is the current directory
+	Env      map[string]string // Added to the base environment
+	BaseEnv  []string          // Base environment in "key=value" form, default is the environment of the current process
+	Stdout   io.Writer         // Default is os.Stdout
+	Stderr   io.Writer         // Default is os.Stderr
+}
+
+func RunWithPriority(ctx context.Context, priority PriorityLevel, env map[string]string, cmd string, args ...string) error {
+	return RunWithOptions(ctx, RunOptions{Priority: priority, Env: env}, cmd, args...)
+}
+
+// RunWithOptions runs the command with the given priority, working directory and environment.
+// The working directory is set on the command only, so it is safe to call concurrently. When ctx is
+// done the command and its children are interrupted, and the command is killed if it has not exited
+// after cancelWaitDelay.
+func RunWithOptions(ctx context.Context, opt RunOptions, cmd string, args ...string) error {
+	execCmd := exec.CommandContext(ctx, cmd, args...)
+	// A command that cannot be cancelled stays in the foreground process group, so it still receives
+	// Ctrl-C from the terminal.
+	if ctx.Done() != nil {
+		interruptOnCancel(execCmd)
+	}
+	execCmd.Dir = opt.Dir
+	execCmd.Env = opt.BaseEnv
+	if execCmd.Env == nil {
+		execCmd.Env = os.Environ()
+	}
+	for k, v := range opt.Env {
 		execCmd.Env = append(execCmd.Env, k+"="+v)
 	}
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
+	execCmd.Stdout = opt.Stdout
+	if execCmd.Stdout == nil {
+		execCmd.Stdout = os.Stdout
+	}
+	execCmd.Stderr = opt.Stderr
+	if execCmd.Stderr == nil {
+		execCmd.Stderr = os.Stderr
+	}
 
 	if err := execCmd.Start(); err != nil {
 		return err
 	}
 
 	pid := execCmd.Process.Pid
-	if err := SetPriority(pid, priority); err != nil {
+	if err := SetPriority(pid, opt.Priority); err != nil {
 		PrintYellow(fmt.Sprintf("Failed to set priority for PID %d: %v", pid, err))
 	}
 
 	return execCmd.Wait()
 }
+
+// interruptOnCancel makes a command created by exec.CommandContext interrupt its process group when
+// the context is done, and kills the command if it has not exited after cancelWaitDelay.
+func interruptOnCancel(cmd *exec.Cmd) {
+	startProcessGroup(cmd)
+	cmd.Cancel = func() error { return interruptProcessGroup(cmd.Process) }
+	cmd.WaitDelay = cancelWaitDelay
+}
+
+// killOnCancel makes a command created by exec.CommandContext kill its process group, including the
+// processes it started, when the context is done.
+func killOnCancel(cmd *exec.Cmd) {
+	startProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process) }
+	cmd.WaitDelay = cancelWaitDelay
+}
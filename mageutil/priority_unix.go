@@ -3,6 +3,8 @@
 package mageutil
 
 import (
+	"os"
+	"os/exec"
 	"syscall"
 )
 
@@ -20,3 +22,19 @@ func SetPriority(pid int, level PriorityLevel) error {
 	}
 	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
 }
+
+// startProcessGroup starts the command in a process group of its own, so the compiler processes
+// started by go build can be interrupted with it.
+func startProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// interruptProcessGroup interrupts the process group of p, as Ctrl-C in a terminal does.
+func interruptProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGINT)
+}
+
+// killProcessGroup kills the process group of p, including the processes p started.
+func killProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
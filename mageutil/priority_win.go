@@ -3,6 +3,10 @@
 package mageutil
 
 import (
+	"os"
+	"os/exec"
+	"strconv"
+
 	"golang.org/x/sys/windows"
 )
 
@@ -27,3 +31,18 @@ func SetPriority(pid int, level PriorityLevel) error {
 
 	return windows.SetPriorityClass(handle, class)
 }
+
+func startProcessGroup(*exec.Cmd) {}
+
+// interruptProcessGroup kills the process, windows cannot deliver an interrupt to another process.
+func interruptProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// killProcessGroup kills p together with the processes it started.
+func killProcessGroup(p *os.Process) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err != nil {
+		return p.Kill()
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -43,6 +44,23 @@ func StartBinaries(specificBinaries ...string) error {
 			continue
 		}
 
+		if err := recordStartedHash(binary, binFullPath); err != nil {
+			PrintYellow(fmt.Sprintf("Failed to record the binary of %s: %v", binary, err))
+		}
+		cover := builtWithFlag(binFullPath, "-cover")
+		race := builtWithFlag(binFullPath, "-race")
+		if cover {
+			// The coverage report covers the latest run only.
+			if err := os.RemoveAll(coverageServiceDir(binary)); err != nil {
+				return fmt.Errorf("failed to remove coverage data of %s: %v", binary, err)
+			}
+		}
+		if race {
+			// The race report covers the latest run only.
+			if err := os.RemoveAll(raceLogDir(binary)); err != nil {
+				return fmt.Errorf("failed to remove race logs of %s: %v", binary, err)
+			}
+		}
 		for i := 0; i < count; i++ {
 			configPath := Paths.Config
 			if os.Getenv(DeploymentType) == KUBERNETES {
@@ -54,6 +72,11 @@ func StartBinaries(specificBinaries ...string) error {
 			cmd.Dir = Paths.OutputHostBin
 			cmd.Stdout = os.Stdout
 			cmd.Stderr = os.Stderr
+			env, err := instanceEnv(binary, i, cover, race)
+			if err != nil {
+				return err
+			}
+			cmd.Env = env
 			if err := cmd.Start(); err != nil {
 				return fmt.Errorf("failed to start %s with args %v: %v", binFullPath, args, err)
 			}
@@ -62,6 +85,31 @@ func StartBinaries(specificBinaries ...string) error {
 	return nil
 }
 
+// instanceEnv returns the environment of one instance of an instrumented service, or nil to inherit
+// the environment unchanged.
+func instanceEnv(binary string, index int, cover, race bool) ([]string, error) {
+	if !cover && !race {
+		return nil, nil
+	}
+	env := os.Environ()
+	if cover {
+		// Each instance writes its coverage data to its own directory, merged by mage coverage.
+		dir, err := coverageDir(binary, index)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "GOCOVERDIR="+dir)
+	}
+	if race {
+		logPath, err := raceLogPath(binary, index)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "GORACE="+strings.TrimSpace(os.Getenv("GORACE")+" log_path="+logPath))
+	}
+	return env, nil
+}
+
 // StartTools starts all tool binaries or specified ones.
 func StartTools(specificTools ...string) error {
 	var toolsToStart []string
@@ -110,16 +158,23 @@ func StartTools(specificTools ...string) error {
 
 // KillExistBinaries iterates over all binary files and kills their corresponding processes.
 func KillExistBinaries() {
+	killServiceBinaries(configuredServiceNames())
+}
+
+func killServiceBinaries(services []string) {
 	var paths []string
-	for binary := range serviceBinaries {
-		fullPath := GetBinFullPath(binary)
-		paths = append(paths, fullPath)
+	for _, binary := range services {
+		paths = append(paths, serviceBinaryPaths(binary)...)
 	}
 	BatchKillExistBinaries(paths)
 }
 
 // CheckBinariesStop checks if all binary files have stopped and returns an error if there are any binaries still running.
 func CheckBinariesStop() error {
+	return checkServicesStop(configuredServiceNames())
+}
+
+func checkServicesStop(services []string) error {
 	var runningBinaries []string
 
 	ps, err := FetchProcesses()
@@ -127,10 +182,12 @@ func CheckBinariesStop() error {
 		return err
 	}
 
-	for binary := range serviceBinaries {
-		fullPath := GetBinFullPath(binary)
-		if CheckProcessInMap(ps, fullPath) {
-			runningBinaries = append(runningBinaries, binary)
+	for _, binary := range services {
+		for _, fullPath := range serviceBinaryPaths(binary) {
+			if CheckProcessInMap(ps, fullPath) {
+				runningBinaries = append(runningBinaries, binary)
+				break
+			}
 		}
 	}
 
@@ -165,16 +222,30 @@ func CheckBinariesRunning() error {
 	return nil
 }
 
-// PrintListenedPortsByBinaries iterates over all binary files and prints the ports they are listening on.
+// PrintListenedPortsByBinaries prints the sockets of all binary services as a table grouped by service and instance.
 func PrintListenedPortsByBinaries() error {
 	ps, err := FindPIDsByBinaryPath()
 	if err != nil {
 		return err
 	}
+
+	binaries := make([]string, 0, len(serviceBinaries))
 	for binary := range serviceBinaries {
-		basePath := GetBinFullPath(binary)
-		fullPath := basePath
-		PrintBinaryPorts(fullPath, ps)
+		binaries = append(binaries, binary)
+	}
+	sort.Strings(binaries)
+
+	var instances []InstanceSockets
+	for _, binary := range binaries {
+		fullPath := GetBinFullPath(binary)
+		if len(ps[fullPath]) == 0 {
+			fmt.Printf("No running processes found for binary: %s\n", fullPath)
+			continue
+		}
+		instances = append(instances, CollectBinarySockets(fullPath, ps)...)
+	}
+	if len(instances) > 0 {
+		PrintSocketTable(instances)
 	}
 	return nil
 }
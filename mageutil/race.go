@@ -0,0 +1,205 @@
+package mageutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	RaceDir          = "race"
+	dataRaceWarning  = "WARNING: DATA RACE"
+	maxRaceLocations = 10
+)
+
+// raceVariant is set while Paths points to the output tree of the race-detector variant.
+var raceVariant bool
+
+// useRaceVariant points Paths to the race-detector output tree under <output>/race, so race builds
+// do not replace the normal binaries. Logs stay in the main output directory. The returned function
+// restores the previous paths.
+func useRaceVariant() (func(), error) {
+	if raceVariant {
+		return func() {}, nil
+	}
+	original := Paths
+	paths, err := nestedOutputPaths(original, RaceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up race output directory: %w", err)
+	}
+	paths.OutputLogs = original.OutputLogs
+	Paths, raceVariant = paths, true
+	return func() { Paths, raceVariant = original, false }, nil
+}
+
+// raceServicePath returns the path of a service in the race-detector output tree.
+func raceServicePath(binary string) string {
+	output := Paths.Output
+	if !raceVariant {
+		output = filepath.Join(output, RaceDir)
+	}
+	return filepath.Join(output, BinDir, PlatformsDir, OsArch(), binary)
+}
+
+// serviceBinaryPaths returns the paths a service may be running from, the normal binary and the
+// race-detector variant.
+func serviceBinaryPaths(binary string) []string {
+	paths := []string{GetBinFullPath(binary)}
+	if racePath := raceServicePath(binary); !slices.Contains(paths, racePath) {
+		paths = append(paths, racePath)
+	}
+	return paths
+}
+
+func raceLogDir(binary string) string {
+	return filepath.Join(Paths.OutputLogs, RaceDir, strings.TrimSuffix(binary, ".exe"))
+}
+
+// raceLogPath returns the GORACE log_path of one instance of a service, the runtime appends the pid.
+// GORACE options are separated by spaces, so a path containing whitespace cannot be passed.
+func raceLogPath(binary string, index int) (string, error) {
+	dir := raceLogDir(binary)
+	if strings.ContainsAny(dir, " \t\n") {
+		return "", fmt.Errorf("race log directory %q contains whitespace, which GORACE log_path cannot express, use an output directory without spaces", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create race log directory %s: %v", dir, err)
+	}
+	return filepath.Join(dir, strconv.Itoa(index)), nil
+}
+
+// RaceSummary lists the data races reported by the instances of one service.
+type RaceSummary struct {
+	Service   string
+	Logs      []string // Race log files, one per instance that reported
+	Races     int
+	Locations []string // Distinct locations of the first access of each race
+}
+
+// CollectRaceReports reads the race logs of the services started from the race-detector variant.
+func CollectRaceReports() ([]*RaceSummary, error) {
+	root := filepath.Join(Paths.OutputLogs, RaceDir)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read race log directory %s: %v", root, err)
+	}
+
+	var summaries []*RaceSummary
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		summary := &RaceSummary{Service: entry.Name()}
+		logs, err := os.ReadDir(filepath.Join(root, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read race logs of %s: %v", entry.Name(), err)
+		}
+		for _, log := range logs {
+			if log.IsDir() {
+				continue
+			}
+			path := filepath.Join(root, entry.Name(), log.Name())
+			if err := summary.readLog(path); err != nil {
+				return nil, err
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// readLog counts the races of one log file and records where the first access of each happened.
+func (s *RaceSummary) readLog(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open race log %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var races, frame int
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var function string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == dataRaceWarning:
+			races++
+			frame = 1
+		case frame == 1 && strings.Contains(line, " by "):
+			// "Write at 0x... by goroutine 7:" is followed by the function and its location.
+			frame = 2
+		case frame == 2:
+			function, frame = line, 3
+		case frame == 3:
+			// A truncated log may end the frame without its location.
+			if fields := strings.Fields(line); len(fields) > 0 {
+				location := function + " " + fields[0]
+				if !slices.Contains(s.Locations, location) {
+					s.Locations = append(s.Locations, location)
+				}
+			}
+			frame = 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read race log %s: %v", path, err)
+	}
+	if races > 0 {
+		s.Logs = append(s.Logs, path)
+		s.Races += races
+	}
+	return nil
+}
+
+// RaceReport prints the data races found per service in the latest run of the race-detector
+// variant and returns an error if there are any.
+func RaceReport() error {
+	summaries, err := CollectRaceReports()
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		PrintYellow(fmt.Sprintf("No race logs in %s, build with --race and start with --race first", filepath.Join(Paths.OutputLogs, RaceDir)))
+		return nil
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tRACES\tLOGS")
+	var total int
+	for _, s := range summaries {
+		logs := "-"
+		if len(s.Logs) > 0 {
+			logs = strings.Join(s.Logs, ", ")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", s.Service, s.Races, logs)
+		total += s.Races
+	}
+	_ = tw.Flush()
+
+	if total == 0 {
+		_, _ = Print(PrintOptions{Color: ColorGreen, Message: b.String(), NoNewLine: true})
+		PrintGreen("No data races found")
+		return nil
+	}
+	_, _ = Print(PrintOptions{Color: ColorRed, Message: b.String(), NoNewLine: true})
+	for _, s := range summaries {
+		for i, location := range s.Locations {
+			if i == maxRaceLocations {
+				PrintRedNoTimeStamp(fmt.Sprintf("  %s: %d more locations, see the logs", s.Service, len(s.Locations)-i))
+				break
+			}
+			PrintRedNoTimeStamp(fmt.Sprintf("  %s: %s", s.Service, location))
+		}
+	}
+	return fmt.Errorf("%d data races found", total)
+}
@@ -0,0 +1,61 @@
+package mageutil
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRaceSummaryReadLog(t *testing.T) {
+	tests := []struct {
+		log       string
+		races     int
+		locations []string
+	}{
+		{
+			log:   "racy.log",
+			races: 2,
+			locations: []string{
+				"main.increment() /tmp/racy/main.go:11",
+				"main.main() /tmp/racy/main.go:18",
+			},
+		},
+		{log: "truncated.log", races: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.log, func(t *testing.T) {
+			path := filepath.Join("testdata", "race", tt.log)
+			s := &RaceSummary{Service: "racy"}
+			if err := s.readLog(path); err != nil {
+				t.Fatalf("readLog failed: %v", err)
+			}
+			if s.Races != tt.races {
+				t.Errorf("Races = %d, want %d", s.Races, tt.races)
+			}
+			if !slices.Equal(s.Locations, tt.locations) {
+				t.Errorf("Locations = %q, want %q", s.Locations, tt.locations)
+			}
+			if !slices.Equal(s.Logs, []string{path}) {
+				t.Errorf("Logs = %q, want %q", s.Logs, []string{path})
+			}
+		})
+	}
+}
+
+func TestRaceLogPathRejectsWhitespace(t *testing.T) {
+	root := useTempPaths(t)
+
+	path, err := raceLogPath("openim-api", 0)
+	if err != nil {
+		t.Fatalf("raceLogPath failed: %v", err)
+	}
+	if want := filepath.Join(root, OutputDir, LogsDir, RaceDir, "openim-api", "0"); path != want {
+		t.Errorf("raceLogPath = %s, want %s", path, want)
+	}
+
+	Paths.OutputLogs = filepath.Join(root, "output logs")
+	if _, err := raceLogPath("openim-api", 0); err == nil || !strings.Contains(err.Error(), "contains whitespace") {
+		t.Errorf("raceLogPath with a space in the log directory returned %v, want an error", err)
+	}
+}
@@ -0,0 +1,313 @@
+package mageutil
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	BuildReportFile      = "build.json"
+	BuildJUnitReportFile = "build-junit.xml"
+)
+
+// BuildReport is the machine readable summary of a build written to the reports directory.
+type BuildReport struct {
+	StartedAt  time.Time           `json:"startedAt"`
+	FinishedAt time.Time           `json:"finishedAt"`
+	DurationMs int64               `json:"durationMs"`
+	Succeeded  bool                `json:"succeeded"`
+	Summary    BuildReportSummary  `json:"summary"`
+	Results    []BuildReportResult `json:"results"`
+	// Sizes holds the last known size of every binary built so far, keyed like the build manifest,
+	// including binaries that were not part of this build.
+	Sizes map[string]int64 `json:"sizes,omitempty"`
+}
+
+type BuildReportSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	UpToDate  int `json:"upToDate"`
+	Failed    int `json:"failed"`
+	Aborted   int `json:"aborted"`
+	Cancelled int `json:"cancelled"`
+}
+
+type BuildReportResult struct {
+	Binary         string            `json:"binary"`
+	Module         string            `json:"module,omitempty"`
+	Name           string            `json:"name"`
+	Platform       string            `json:"platform"`
+	Tool           bool              `json:"tool"`
+	Status         BuildStatus       `json:"status"`
+	DurationMs     int64             `json:"durationMs"`
+	Output         string            `json:"output,omitempty"`
+	Size           int64             `json:"size"`
+	SHA256         string            `json:"sha256,omitempty"`
+	PreviousSize   *int64            `json:"previousSize,omitempty"` // Size in the previous report, nil if the binary was not built before
+	SizeDelta      *int64            `json:"sizeDelta,omitempty"`
+	CompilerOutput string            `json:"compilerOutput,omitempty"`
+	Steps          []BuildReportStep `json:"steps,omitempty"`
+	Error          string            `json:"error,omitempty"`
+}
+
+// BuildReportStep is the outcome of a post-build step in the JSON report.
+type BuildReportStep struct {
+	Name       string                 `json:"name"`
+	Step       string                 `json:"step"`
+	Policy     PostBuildFailurePolicy `json:"onFailure"`
+	Status     PostBuildStepStatus    `json:"status"`
+	DurationMs int64                  `json:"durationMs"`
+	Output     string                 `json:"output,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// WriteBuildReports writes the JSON and JUnit XML reports of a build to the reports directory.
+// Size changes are computed against the sizes recorded by the previous reports, which are carried
+// forward for binaries this build did not include.
+func WriteBuildReports(results BuildResults, startedAt time.Time) error {
+	if err := os.MkdirAll(Paths.OutputReports, 0755); err != nil {
+		return fmt.Errorf("failed to create reports directory %s: %v", Paths.OutputReports, err)
+	}
+	jsonPath := filepath.Join(Paths.OutputReports, BuildReportFile)
+	previousSizes := loadPreviousSizes(jsonPath)
+
+	report := &BuildReport{
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Succeeded:  results.Err() == nil,
+		Results:    []BuildReportResult{},
+		Sizes:      previousSizes,
+	}
+	report.DurationMs = report.FinishedAt.Sub(startedAt).Milliseconds()
+
+	for _, r := range results.sorted() {
+		entry := BuildReportResult{
+			Binary:         filepath.ToSlash(r.Binary),
+			Module:         r.Module,
+			Name:           r.Name,
+			Platform:       r.Platform,
+			Tool:           r.Tool,
+			Status:         r.Status,
+			DurationMs:     r.Duration.Milliseconds(),
+			CompilerOutput: r.CompilerOutput,
+		}
+		if r.Err != nil {
+			entry.Error = r.Err.Error()
+		}
+		for _, step := range r.Steps {
+			reportStep := BuildReportStep{
+				Name:       step.Name,
+				Step:       step.Step,
+				Policy:     step.Policy,
+				Status:     step.Status,
+				DurationMs: step.Duration.Milliseconds(),
+				Output:     step.Output,
+			}
+			if step.Err != nil {
+				reportStep.Error = step.Err.Error()
+			}
+			entry.Steps = append(entry.Steps, reportStep)
+		}
+		if r.OK() {
+			entry.Output = r.Output
+			entry.Size = r.Size
+			entry.SHA256 = r.SHA256
+			key := manifestKey(r.Platform, r.Binary)
+			if prev, ok := previousSizes[key]; ok {
+				delta := r.Size - prev
+				entry.PreviousSize, entry.SizeDelta = &prev, &delta
+			}
+			report.Sizes[key] = r.Size
+		} else if prev, ok := previousSizes[manifestKey(r.Platform, r.Binary)]; ok {
+			// Keep the last known size so the next successful build still reports a delta.
+			entry.PreviousSize = &prev
+		}
+		report.Results = append(report.Results, entry)
+
+		report.Summary.Total++
+		switch r.Status {
+		case BuildSucceeded:
+			report.Summary.Succeeded++
+		case BuildUpToDate:
+			report.Summary.UpToDate++
+		case BuildFailed:
+			report.Summary.Failed++
+		case BuildAborted:
+			report.Summary.Aborted++
+		case BuildCancelled:
+			report.Summary.Cancelled++
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal build report: %v", err)
+	}
+	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write build report %s: %v", jsonPath, err)
+	}
+
+	junitPath := filepath.Join(Paths.OutputReports, BuildJUnitReportFile)
+	if err := writeJUnitReport(junitPath, report); err != nil {
+		return err
+	}
+	PrintBlue(fmt.Sprintf("Build reports written to %s and %s", jsonPath, junitPath))
+	return nil
+}
+
+// loadPreviousSizes returns the binary sizes of the previous report keyed like the build manifest.
+// Failed entries carry over their previous size. The returned map is owned by the caller.
+func loadPreviousSizes(path string) map[string]int64 {
+	sizes := make(map[string]int64)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sizes
+	}
+	var prev BuildReport
+	if err := json.Unmarshal(data, &prev); err != nil {
+		PrintYellow(fmt.Sprintf("Ignoring invalid build report %s: %v", path, err))
+		return sizes
+	}
+	for key, size := range prev.Sizes {
+		sizes[key] = size
+	}
+	for _, r := range prev.Results {
+		switch {
+		case r.Size > 0:
+			sizes[manifestKey(r.Platform, r.Binary)] = r.Size
+		case r.PreviousSize != nil:
+			sizes[manifestKey(r.Platform, r.Binary)] = *r.PreviousSize
+		}
+	}
+	return sizes
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Failure    *junitFailure    `xml:"failure,omitempty"`
+	Skipped    *junitSkipped    `xml:"skipped,omitempty"`
+	SystemOut  string           `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// writeJUnitReport writes the report as JUnit XML with one test suite per platform and one
+// test case per binary. Aborted and cancelled builds are reported as skipped.
+func writeJUnitReport(path string, report *BuildReport) error {
+	suites := junitTestSuites{
+		Name: "gomake build",
+		Time: junitSeconds(report.DurationMs),
+	}
+	suiteIndex := make(map[string]int)
+	var suiteMs []int64
+
+	for _, r := range report.Results {
+		i, ok := suiteIndex[r.Platform]
+		if !ok {
+			i = len(suites.Suites)
+			suiteIndex[r.Platform] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{
+				Name:      "build " + r.Platform,
+				Timestamp: report.StartedAt.UTC().Format(time.RFC3339),
+			})
+			suiteMs = append(suiteMs, 0)
+		}
+		suite := &suites.Suites[i]
+		suiteMs[i] += r.DurationMs
+
+		tc := junitTestCase{
+			Name:      r.Name,
+			ClassName: "build." + r.Platform,
+			Time:      junitSeconds(r.DurationMs),
+			SystemOut: strings.TrimSpace(r.CompilerOutput),
+		}
+		props := []junitProperty{
+			{Name: "binary", Value: r.Binary},
+			{Name: "module", Value: r.Module},
+			{Name: "status", Value: string(r.Status)},
+			{Name: "size", Value: fmt.Sprint(r.Size)},
+		}
+		if r.SizeDelta != nil {
+			props = append(props, junitProperty{Name: "sizeDelta", Value: fmt.Sprint(*r.SizeDelta)})
+		}
+		tc.Properties = &junitProperties{Properties: props}
+
+		switch r.Status {
+		case BuildFailed:
+			tc.Failure = &junitFailure{Message: r.Error, Type: "CompileError", Text: r.CompilerOutput}
+			tc.SystemOut = ""
+			suite.Failures++
+			suites.Failures++
+		case BuildAborted, BuildCancelled:
+			tc.Skipped = &junitSkipped{Message: r.Error}
+			suite.Skipped++
+			suites.Skipped++
+		}
+		suite.Tests++
+		suites.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	for i := range suites.Suites {
+		suites.Suites[i].Time = junitSeconds(suiteMs[i])
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal junit report: %v", err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write junit report %s: %v", path, err)
+	}
+	return nil
+}
+
+func junitSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
@@ -0,0 +1,77 @@
+package mageutil
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTempPaths points Paths to a new root directory for the duration of a test and returns it.
+func useTempPaths(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	original := Paths
+	t.Cleanup(func() { Paths = original })
+	paths, err := NewPathConfig(&PathOptions{RootDir: &root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Paths = paths
+	return root
+}
+
+func readBuildReport(t *testing.T) *BuildReport {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(Paths.OutputReports, BuildReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report BuildReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	return &report
+}
+
+func TestWriteBuildReportsKeepsSizes(t *testing.T) {
+	useTempPaths(t)
+	built := func(binary string, size int64) *BuildResult {
+		return &BuildResult{Binary: binary, Name: filepath.Base(binary), Platform: "linux_amd64", Status: BuildSucceeded, Size: size}
+	}
+
+	// A full build, then a subset build, then a build with nothing to do, then a full build again.
+	builds := []BuildResults{
+		{built("cmd/openim-api", 100), built("cmd/openim-rpc-user", 200)},
+		{built("cmd/openim-api", 110)},
+		nil,
+		{built("cmd/openim-api", 120), built("cmd/openim-rpc-user", 230)},
+	}
+	for _, results := range builds {
+		if err := WriteBuildReports(results, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := readBuildReport(t)
+	want := map[string]struct{ previous, delta int64 }{
+		"cmd/openim-api":      {previous: 110, delta: 10},
+		"cmd/openim-rpc-user": {previous: 200, delta: 30},
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("report has %d results, want %d", len(report.Results), len(want))
+	}
+	for _, r := range report.Results {
+		w := want[r.Binary]
+		if r.PreviousSize == nil || *r.PreviousSize != w.previous {
+			t.Errorf("%s previous size = %v, want %d", r.Binary, r.PreviousSize, w.previous)
+		}
+		if r.SizeDelta == nil || *r.SizeDelta != w.delta {
+			t.Errorf("%s size delta = %v, want %d", r.Binary, r.SizeDelta, w.delta)
+		}
+	}
+	if got := report.Sizes[manifestKey("linux_amd64", "cmd/openim-rpc-user")]; got != 230 {
+		t.Errorf("recorded size of openim-rpc-user = %d, want 230", got)
+	}
+}
@@ -0,0 +1,158 @@
+package mageutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openimsdk/gomake/internal/util"
+)
+
+const ReproDir = "repro"
+
+// reproducibleEnvAllowlist are the variables passed to the compiler in reproducible mode. They locate
+// the toolchain, caches and module sources but do not change the output. The platform, cgo and
+// toolchain variables are set explicitly by the build.
+var reproducibleEnvAllowlist = []string{
+	"PATH", "HOME", "USER", "TMPDIR", "TMP", "TEMP",
+	"GOROOT", "GOPATH", "GOCACHE", "GOMODCACHE", "GOTOOLCHAIN", "GOENV",
+	"GOPROXY", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOSUMDB", "GOINSECURE", "GOAUTH",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	"SystemRoot", "SYSTEMROOT", "ComSpec", "USERPROFILE", "LOCALAPPDATA", "APPDATA", "ProgramData",
+}
+
+// reproducibleEnv returns the allowlisted part of the current environment.
+func reproducibleEnv() []string {
+	env := []string{}
+	for _, key := range reproducibleEnvAllowlist {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// verifyReproducibleBuild builds the binaries twice in reproducible mode into separate output
+// directories, the second time with an empty build cache, and fails if any binary differs.
+// The builds compile only, without hooks, post-build steps, reports or restarts, so nothing outside
+// the repro directories is affected. It returns the results of the second build.
+func verifyReproducibleBuild(ctx context.Context, binaries []string, buildOpt *BuildOptions) (BuildResults, error) {
+	reproducible, force, verify, restart := true, true, false, false
+	opt := *buildOpt
+	opt.Reproducible, opt.Force, opt.VerifyRepro, opt.Restart = &reproducible, &force, &verify, &restart
+
+	compileBinaries, err := getBinaries(binaries, &opt)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOutputNameCollisions(compileBinaries); err != nil {
+		return nil, err
+	}
+
+	original := Paths
+	defer func() { Paths = original }()
+
+	cacheDir, err := os.MkdirTemp("", "gomake-repro-cache-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build cache directory: %v", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	var runs [2]BuildResults
+	for i, name := range []string{"a", "b"} {
+		paths, err := nestedOutputPaths(original, ReproDir, name)
+		if err != nil {
+			return nil, err
+		}
+		Paths = paths
+		PrintBlue(fmt.Sprintf("Reproducibility build %d of 2 into %s", i+1, Paths.Output))
+
+		env := map[string]string{}
+		if i == 1 {
+			// A cold cache makes sure the second build does not reuse the objects of the first.
+			env["GOCACHE"] = cacheDir
+		}
+		restore, err := util.SetEnvs(env)
+		if err != nil {
+			return nil, err
+		}
+		runs[i], err = compileAll(ctx, compileBinaries, opt.GetPlatforms(), &opt, false)
+		restore()
+		if err != nil {
+			return nil, fmt.Errorf("reproducibility build %d failed: %w", i+1, err)
+		}
+		if ctx.Err() != nil {
+			return runs[i], fmt.Errorf("reproducibility build %d was cancelled", i+1)
+		}
+		if err := runs[i].Err(); err != nil {
+			PrintBuildSummary(runs[i])
+			return runs[i], fmt.Errorf("reproducibility build %d failed: %w", i+1, err)
+		}
+	}
+
+	PrintBuildSummary(runs[1])
+	return runs[1], compareReproBuilds(runs[0], runs[1])
+}
+
+// nestedOutputPaths returns the path configuration of the original with the output directory moved
+// to a subdirectory of the original output, such as <output>/repro/a.
+func nestedOutputPaths(original *PathConfig, elem ...string) (*PathConfig, error) {
+	configDir, err := filepath.Rel(original.Root, original.Config)
+	if err != nil {
+		return nil, err
+	}
+	outputDir, err := filepath.Rel(original.Root, filepath.Join(append([]string{original.Output}, elem...)...))
+	if err != nil {
+		return nil, err
+	}
+	root, srcDir, toolsDir := original.Root, original.SrcDir, original.ToolsDir
+	return NewPathConfig(&PathOptions{
+		RootDir:   &root,
+		OutputDir: &outputDir,
+		ConfigDir: &configDir,
+		SrcDir:    &srcDir,
+		ToolsDir:  &toolsDir,
+	})
+}
+
+func compareReproBuilds(first, second BuildResults) error {
+	digests := make(map[string]string)
+	for _, r := range first {
+		digests[manifestKey(r.Platform, r.Binary)] = r.SHA256
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BINARY\tPLATFORM\tFIRST\tSECOND")
+	var mismatches int
+	for _, r := range second.sorted() {
+		firstSum, ok := digests[manifestKey(r.Platform, r.Binary)]
+		if ok && firstSum == r.SHA256 && r.SHA256 != "" {
+			continue
+		}
+		mismatches++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Name, r.Platform, shortDigest(firstSum), shortDigest(r.SHA256))
+	}
+	_ = tw.Flush()
+
+	if mismatches > 0 || len(first) != len(second) {
+		_, _ = Print(PrintOptions{Color: ColorRed, Message: b.String(), NoNewLine: true})
+		return fmt.Errorf("%d of %d binaries are not reproducible", max(mismatches, 1), len(second))
+	}
+	PrintGreen(fmt.Sprintf("All %d binaries are reproducible", len(second)))
+	return nil
+}
+
+func shortDigest(sum string) string {
+	if sum == "" {
+		return "-"
+	}
+	if len(sum) > 16 {
+		return sum[:16]
+	}
+	return sum
+}
@@ -0,0 +1,186 @@
+package mageutil
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openimsdk/gomake/internal/util"
+)
+
+type BuildStatus string
+
+const (
+	BuildSucceeded BuildStatus = "succeeded"
+	BuildUpToDate  BuildStatus = "up-to-date" // Skipped because the build manifest says the binary is unchanged
+	BuildFailed    BuildStatus = "failed"
+	BuildAborted   BuildStatus = "aborted"   // Not compiled because another binary failed and keep-going is off
+	BuildCancelled BuildStatus = "cancelled" // Not compiled, or interrupted, because the build was cancelled
+)
+
+// BuildResult is the outcome of building one binary for one platform.
+type BuildResult struct {
+	Binary         string // Root-relative source path
+	Module         string // Path of the module the binary belongs to
+	Name           string // Output file name, with .exe on windows
+	Platform       string
+	Tool           bool // Whether the binary is under the tools directory
+	Status         BuildStatus
+	Duration       time.Duration
+	Output         string                 // Output path of the binary
+	Size           int64                  // Size of the binary in bytes, zero unless it was built or up to date
+	SHA256         string                 // Hex encoded digest of the binary, empty unless it was built or up to date
+	CompilerOutput string                 // Combined stdout and stderr of go build
+	Steps          []*PostBuildStepResult // Post-build steps run on the binary, in order
+	Err            error
+
+	buildInfo *debug.BuildInfo // Read before the post-build steps, which may make it unreadable
+}
+
+func (r *BuildResult) OK() bool {
+	return r.Status == BuildSucceeded || r.Status == BuildUpToDate
+}
+
+type BuildResults []*BuildResult
+
+// Failed returns the results of the binaries that failed to build.
+func (rs BuildResults) Failed() BuildResults {
+	var failed BuildResults
+	for _, r := range rs {
+		if r.Status == BuildFailed {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Cancelled returns the results of the binaries whose compilation was cancelled.
+func (rs BuildResults) Cancelled() BuildResults {
+	var cancelled BuildResults
+	for _, r := range rs {
+		if r.Status == BuildCancelled {
+			cancelled = append(cancelled, r)
+		}
+	}
+	return cancelled
+}
+
+// Err joins the errors of all failed, aborted and cancelled builds, it is nil when every binary was built.
+func (rs BuildResults) Err() error {
+	var errs []error
+	for _, r := range rs {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (rs BuildResults) sorted() BuildResults {
+	sorted := append(BuildResults(nil), rs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Platform != sorted[j].Platform {
+			return sorted[i].Platform < sorted[j].Platform
+		}
+		return sorted[i].Binary < sorted[j].Binary
+	})
+	return sorted
+}
+
+// PrintBuildSummary prints a table of the build results followed by the compiler output of every failure.
+func PrintBuildSummary(results BuildResults) {
+	if len(results) == 0 {
+		PrintYellow("No binaries were built.")
+		return
+	}
+	results = results.sorted()
+
+	// The module column is only useful when the binaries come from several workspace modules.
+	modules := make(map[string]struct{})
+	for _, r := range results {
+		if r.Module != "" {
+			modules[r.Module] = struct{}{}
+		}
+	}
+	showModule := len(modules) > 1
+	// Likewise the post-build column is only shown when a pipeline ran.
+	showSteps := slices.ContainsFunc(results, func(r *BuildResult) bool { return len(r.Steps) > 0 })
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	header := "BINARY\tPLATFORM\tSTATUS\tDURATION\tSIZE\tOUTPUT"
+	if showModule {
+		header = "BINARY\tMODULE\tPLATFORM\tSTATUS\tDURATION\tSIZE\tOUTPUT"
+	}
+	if showSteps {
+		header += "\tPOST-BUILD"
+	}
+	fmt.Fprintln(tw, header)
+	for _, r := range results {
+		size, output := "-", "-"
+		if r.OK() {
+			size = util.FormatBytes(uint64(r.Size))
+			output = r.Output
+		}
+		name := r.Name
+		if showModule {
+			name += "\t" + r.Module
+		}
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", name, r.Platform, r.Status, r.Duration.Round(time.Millisecond), size, output)
+		if showSteps {
+			row += "\t" + formatStepStatuses(r.Steps)
+		}
+		fmt.Fprintln(tw, row)
+	}
+	_ = tw.Flush()
+
+	failed := results.Failed()
+	color := ColorGreen
+	if len(failed) > 0 {
+		color = ColorRed
+	} else if len(results.Cancelled()) > 0 {
+		color = ColorYellow
+	}
+	_, _ = Print(PrintOptions{Color: color, Message: b.String(), NoNewLine: true})
+
+	for _, r := range failed {
+		PrintRed(fmt.Sprintf("%s for %s failed: %v", r.Name, r.Platform, r.Err))
+		if out := strings.TrimSpace(r.CompilerOutput); out != "" {
+			PrintRedNoTimeStamp(out)
+		}
+		for _, step := range r.Steps {
+			if out := strings.TrimSpace(step.Output); out != "" && step.Status == PostBuildStepFailed && step.Policy == PostBuildFail {
+				PrintRedNoTimeStamp(out)
+			}
+		}
+	}
+	// Steps whose failure does not fail the binary are reported as warnings.
+	for _, r := range results {
+		for _, step := range r.Steps {
+			if step.Status != PostBuildStepFailed || step.Policy != PostBuildWarn {
+				continue
+			}
+			PrintYellow(fmt.Sprintf("Post-build step %s on %s for %s failed: %v", step.Name, r.Name, r.Platform, step.Err))
+			if out := strings.TrimSpace(step.Output); out != "" {
+				PrintYellow(out)
+			}
+		}
+	}
+}
+
+// formatStepStatuses lists the post-build steps of a binary with their status, such as "upx:ok strip:failed".
+func formatStepStatuses(steps []*PostBuildStepResult) string {
+	if len(steps) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(steps))
+	for _, step := range steps {
+		parts = append(parts, step.Name+":"+string(step.Status))
+	}
+	return strings.Join(parts, " ")
+}
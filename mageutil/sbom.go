@@ -0,0 +1,199 @@
+package mageutil
+
+import (
+	"crypto/rand"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const cycloneDXSpecVersion = "1.5"
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Hashes     []cdxHash     `json:"hashes,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// sbomPath returns the path of the CycloneDX SBOM of a built binary.
+func sbomPath(r *BuildResult) string {
+	return filepath.Join(Paths.OutputSBOM, platformDir(r.Platform), strings.TrimSuffix(r.Name, ".exe")+".cdx.json")
+}
+
+// writeBuildSBOMs writes a CycloneDX SBOM for every built binary from the build info read right after
+// compilation. The SBOM of an up to date binary is kept, if it is missing the build info is read from
+// the binary, which fails if a post-build step such as upx made it unreadable.
+func writeBuildSBOMs(results BuildResults) error {
+	for _, r := range results {
+		if !r.OK() {
+			continue
+		}
+		info := r.buildInfo
+		if info == nil {
+			if _, err := os.Stat(sbomPath(r)); err == nil {
+				continue
+			}
+			var err error
+			if info, err = buildinfo.ReadFile(r.Output); err != nil {
+				return fmt.Errorf("failed to read build info of %s, rebuild it with --force: %v", r.Output, err)
+			}
+		}
+		bom := newCycloneDXBOM(r, info)
+
+		path := sbomPath(r)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create SBOM directory: %v", err)
+		}
+		data, err := json.MarshalIndent(bom, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal SBOM of %s: %v", r.Name, err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write SBOM %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// newCycloneDXBOM describes the binary and the modules linked into it, as recorded by the Go toolchain.
+func newCycloneDXBOM(r *BuildResult, info *debug.BuildInfo) *cdxBOM {
+	mainVersion := info.Main.Version
+	if mainVersion == "(devel)" {
+		mainVersion = ""
+	}
+	main := cdxComponent{
+		Type:    "application",
+		BOMRef:  golangPURL(info.Path, mainVersion),
+		Name:    strings.TrimSuffix(r.Name, ".exe"),
+		Version: mainVersion,
+		PURL:    golangPURL(info.Path, mainVersion),
+	}
+	if r.SHA256 != "" {
+		main.Hashes = []cdxHash{{Alg: "SHA-256", Content: r.SHA256}}
+	}
+	main.Properties = append(main.Properties,
+		cdxProperty{Name: "golang:package", Value: info.Path},
+		cdxProperty{Name: "golang:module", Value: info.Main.Path},
+		cdxProperty{Name: "golang:go_version", Value: info.GoVersion},
+		cdxProperty{Name: "gomake:platform", Value: r.Platform},
+	)
+	for _, setting := range info.Settings {
+		main.Properties = append(main.Properties, cdxProperty{Name: "golang:build:" + setting.Key, Value: setting.Value})
+	}
+
+	bom := &cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: sbomSerialNumber(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: "gomake"}}},
+			Component: main,
+		},
+		Components: []cdxComponent{},
+	}
+
+	stdlib := cdxComponent{
+		Type:    "library",
+		BOMRef:  golangPURL("stdlib", info.GoVersion),
+		Name:    "stdlib",
+		Version: info.GoVersion,
+		PURL:    golangPURL("stdlib", info.GoVersion),
+	}
+	bom.Components = append(bom.Components, stdlib)
+	dependsOn := []string{stdlib.BOMRef}
+
+	for _, dep := range info.Deps {
+		mod := dep
+		if dep.Replace != nil {
+			mod = dep.Replace
+		}
+		c := cdxComponent{
+			Type:    "library",
+			BOMRef:  golangPURL(mod.Path, mod.Version),
+			Name:    mod.Path,
+			Version: mod.Version,
+			PURL:    golangPURL(mod.Path, mod.Version),
+		}
+		if mod.Sum != "" {
+			c.Properties = append(c.Properties, cdxProperty{Name: "golang:sum", Value: mod.Sum})
+		}
+		if dep.Replace != nil {
+			c.Properties = append(c.Properties, cdxProperty{Name: "golang:replaces", Value: dep.Path + "@" + dep.Version})
+		}
+		bom.Components = append(bom.Components, c)
+		dependsOn = append(dependsOn, c.BOMRef)
+	}
+
+	bom.Dependencies = []cdxDependency{{Ref: main.BOMRef, DependsOn: dependsOn}}
+	return bom
+}
+
+// golangPURL returns the package URL of a Go module, see https://github.com/package-url/purl-spec.
+func golangPURL(path, version string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	purl := "pkg:golang/" + strings.Join(segments, "/")
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+	return purl
+}
+
+// sbomSerialNumber returns a random UUID URN. CycloneDX requires a new serial number for every
+// generated BOM, even one describing the same binary.
+func sbomSerialNumber() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4, random
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,19 @@
+package mageutil
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSBOMSerialNumber(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := sbomSerialNumber(), sbomSerialNumber()
+	for _, serial := range []string{first, second} {
+		if !uuidV4.MatchString(serial) {
+			t.Errorf("serial number %s is not a version 4 UUID URN", serial)
+		}
+	}
+	if first == second {
+		t.Errorf("two BOMs got the same serial number %s", first)
+	}
+}
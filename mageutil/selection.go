@@ -0,0 +1,158 @@
+package mageutil
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// binaryGroups are the named groups of binaries declared in the groups section of start-config.yml.
+// A member is a binary name, a shell-style glob or another group prefixed with @.
+var binaryGroups map[string][]string
+
+const groupPrefix = "@"
+
+// expandBinaryNames expands names, globs and @groups into the known binary names they select, without
+// duplicates. A plain name must be in known or accepted by exists, otherwise the error suggests the
+// closest known name. Names are compared without the .exe suffix.
+func expandBinaryNames(names, known []string, exists func(name string) bool) ([]string, error) {
+	known = trimExeSuffixes(known)
+	var expanded []string
+	add := func(name string) {
+		if !slices.Contains(expanded, name) {
+			expanded = append(expanded, name)
+		}
+	}
+
+	var expand func(name string, groups []string) error
+	expand = func(name string, groups []string) error {
+		name = strings.TrimSuffix(strings.TrimSpace(name), ".exe")
+		switch {
+		case strings.HasPrefix(name, groupPrefix):
+			group := strings.TrimPrefix(name, groupPrefix)
+			members, ok := binaryGroups[group]
+			if !ok {
+				return fmt.Errorf("unknown group %q%s", name, didYouMean(name, groupNames()))
+			}
+			if slices.Contains(groups, group) {
+				return fmt.Errorf("group %q includes itself through %s%s", name, groupPrefix, strings.Join(append(groups, group), " -> "+groupPrefix))
+			}
+			for _, member := range members {
+				if err := expand(member, append(groups, group)); err != nil {
+					return fmt.Errorf("in group %q: %w", name, err)
+				}
+			}
+		case isGlobPattern(name):
+			if _, err := path.Match(name, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", name, err)
+			}
+			var matched bool
+			for _, candidate := range known {
+				if ok, _ := path.Match(name, candidate); ok {
+					add(candidate)
+					matched = true
+				}
+			}
+			if !matched {
+				return fmt.Errorf("pattern %q matches no binaries", name)
+			}
+		case slices.Contains(known, name) || (exists != nil && exists(name)):
+			add(name)
+		default:
+			return fmt.Errorf("unknown binary %q%s", name, didYouMean(name, known))
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err := expand(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+func isGlobPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+func groupNames() []string {
+	names := make([]string, 0, len(binaryGroups))
+	for name := range binaryGroups {
+		names = append(names, groupPrefix+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func trimExeSuffixes(names []string) []string {
+	trimmed := make([]string, 0, len(names))
+	for _, name := range names {
+		trimmed = append(trimmed, strings.TrimSuffix(name, ".exe"))
+	}
+	return trimmed
+}
+
+// didYouMean returns a suggestion of the candidate closest to name, or an empty string if none is close.
+func didYouMean(name string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := editDistance(name, candidate)
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(name)/3) {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// configuredBinaryNames returns the service and tool names of start-config.yml without the .exe suffix.
+func configuredBinaryNames() []string {
+	names := trimExeSuffixes(append(configuredServiceNames(), toolBinaries...))
+	sort.Strings(names)
+	return slices.Compact(names)
+}
+
+// selectServices expands names against the binaries of start-config.yml and returns the selected
+// services, as used as keys of serviceBinaries. Selected tools are ignored.
+func selectServices(names []string) ([]string, error) {
+	expanded, err := expandBinaryNames(names, configuredBinaryNames(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	for _, name := range expanded {
+		for _, key := range []string{name, name + ".exe"} {
+			if _, ok := serviceBinaries[key]; ok {
+				selected = append(selected, key)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
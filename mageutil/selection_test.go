@@ -0,0 +1,61 @@
+package mageutil
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandBinaryNames(t *testing.T) {
+	original := binaryGroups
+	t.Cleanup(func() { binaryGroups = original })
+	binaryGroups = map[string][]string{
+		"rpc":   {"openim-rpc-*"},
+		"core":  {"openim-api", "@rpc", "openim-api"},
+		"all":   {"@core", "seq"},
+		"loopa": {"openim-api", "@loopb"},
+		"loopb": {"@loopa"},
+		"typo":  {"openim-rpc-usr"},
+	}
+	known := []string{"openim-api", "openim-rpc-user", "openim-rpc-group", "openim-msggateway", "seq.exe"}
+	exists := func(name string) bool { return name == "openim-extra" }
+
+	tests := []struct {
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{names: []string{"openim-api"}, want: []string{"openim-api"}},
+		{names: []string{"seq.exe"}, want: []string{"seq"}},
+		{names: []string{" seq "}, want: []string{"seq"}},
+		{names: []string{"openim-rpc-*"}, want: []string{"openim-rpc-user", "openim-rpc-group"}},
+		{names: []string{"openim-?pi", "openim-api"}, want: []string{"openim-api"}},
+		{names: []string{"@rpc"}, want: []string{"openim-rpc-user", "openim-rpc-group"}},
+		{names: []string{"@core"}, want: []string{"openim-api", "openim-rpc-user", "openim-rpc-group"}},
+		{names: []string{"@all", "openim-msggateway"}, want: []string{"openim-api", "openim-rpc-user", "openim-rpc-group", "seq", "openim-msggateway"}},
+		{names: []string{"openim-extra"}, want: []string{"openim-extra"}},
+		{names: []string{"openim-apii"}, wantErr: `unknown binary "openim-apii", did you mean "openim-api"?`},
+		{names: []string{"something-else"}, wantErr: `unknown binary "something-else"`},
+		{names: []string{"@rcp"}, wantErr: `unknown group "@rcp", did you mean "@rpc"?`},
+		{names: []string{"@typo"}, wantErr: `in group "@typo": unknown binary "openim-rpc-usr", did you mean "openim-rpc-user"?`},
+		{names: []string{"@loopa"}, wantErr: `group "@loopa" includes itself through @loopa -> @loopb -> @loopa`},
+		{names: []string{"cmd-*"}, wantErr: `pattern "cmd-*" matches no binaries`},
+		{names: []string{"openim-[api"}, wantErr: `invalid pattern "openim-[api"`},
+	}
+	for _, tt := range tests {
+		got, err := expandBinaryNames(tt.names, known, exists)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expandBinaryNames(%q) error = %v, want one containing %q", tt.names, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expandBinaryNames(%q) failed: %v", tt.names, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("expandBinaryNames(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
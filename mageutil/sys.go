@@ -3,8 +3,13 @@ package mageutil
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/openimsdk/gomake/internal/util"
 	"github.com/shirou/gopsutil/v4/net"
@@ -83,50 +88,212 @@ func FindPIDsByBinaryPath() (map[string][]int, error) {
 
 	return pidMap, nil
 }
-func PrintBinaryPorts(binaryPath string, pidMap map[string][]int) {
-	pids, exists := pidMap[binaryPath]
-	if !exists || len(pids) == 0 {
-		fmt.Printf("No running processes found for binary: %s\n", binaryPath)
-		return
-	}
 
-	for _, pid := range pids {
+// SocketInfo describes a socket opened by a service instance.
+type SocketInfo struct {
+	Proto       string // tcp, tcp6, udp, udp6, unix or unixgram
+	Bind        string // Bind address, or the socket path for unix domain sockets
+	Port        uint32
+	State       string
+	Established int // Number of established connections accepted by a listening TCP socket
+}
+
+// InstanceSockets holds the sockets of one running process of a service.
+type InstanceSockets struct {
+	Service  string
+	Instance string // Value of the -i flag, "-" if absent
+	PID      int
+	Cmdline  string
+	Sockets  []SocketInfo
+	Err      error
+}
+
+// CollectBinarySockets returns the sockets of every running process of the binary, ordered by instance.
+func CollectBinarySockets(binaryPath string, pidMap map[string][]int) []InstanceSockets {
+	service := strings.TrimSuffix(filepath.Base(binaryPath), ".exe")
+	var instances []InstanceSockets
+
+	for _, pid := range pidMap[binaryPath] {
+		inst := InstanceSockets{Service: service, Instance: "-", PID: pid}
+
 		proc, err := process.NewProcess(int32(pid))
 		if err != nil {
-			fmt.Printf("Failed to create process object for PID %d: %v\n", pid, err)
+			inst.Err = fmt.Errorf("failed to create process object for PID %d: %v", pid, err)
+			instances = append(instances, inst)
 			continue
 		}
+		if args, err := proc.CmdlineSlice(); err == nil {
+			inst.Cmdline = strings.Join(args, " ")
+			inst.Instance = instanceIndexFromArgs(args)
+		}
 
-		cmdline, err := proc.Cmdline()
+		connections, err := net.ConnectionsPid("all", int32(pid))
 		if err != nil {
-			fmt.Printf("Failed to get command line for PID %d: %v\n", pid, err)
+			inst.Err = fmt.Errorf("error getting connections for PID %d: %v", pid, err)
+			instances = append(instances, inst)
 			continue
 		}
+		inst.Sockets = summarizeSockets(connections)
+		instances = append(instances, inst)
+	}
 
-		connections, err := net.ConnectionsPid("all", int32(pid))
-		if err != nil {
-			fmt.Printf("Error getting connections for PID %d: %v\n", pid, err)
+	sort.SliceStable(instances, func(i, j int) bool {
+		ii, errI := strconv.Atoi(instances[i].Instance)
+		ij, errJ := strconv.Atoi(instances[j].Instance)
+		if errI == nil && errJ == nil && ii != ij {
+			return ii < ij
+		}
+		return instances[i].PID < instances[j].PID
+	})
+	return instances
+}
+
+func instanceIndexFromArgs(args []string) string {
+	for i, arg := range args {
+		if (arg == "-i" || arg == "--i") && i+1 < len(args) {
+			return args[i+1]
+		}
+		if v, ok := strings.CutPrefix(arg, "-i="); ok {
+			return v
+		}
+	}
+	return "-"
+}
+
+// listenKey identifies a listening TCP socket by address family, bind address and port.
+type listenKey struct {
+	family uint32
+	ip     string
+	port   uint32
+}
+
+func isWildcardIP(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::"
+}
+
+// summarizeSockets keeps listening TCP sockets, bound UDP sockets and unix domain sockets with a path,
+// and counts the established TCP connections accepted by each listening socket. A connection belongs
+// to the listener of its family and local port bound to its local address or to the wildcard address,
+// outbound connections that do not match a listener are not counted.
+func summarizeSockets(connections []net.ConnectionStat) []SocketInfo {
+	listeners := make(map[listenKey]bool)
+	for _, conn := range connections {
+		if conn.Type == syscall.SOCK_STREAM && conn.Status == "LISTEN" {
+			listeners[listenKey{conn.Family, conn.Laddr.IP, conn.Laddr.Port}] = true
+		}
+	}
+	established := make(map[listenKey]int)
+	for _, conn := range connections {
+		if conn.Type != syscall.SOCK_STREAM || conn.Status != "ESTABLISHED" {
 			continue
 		}
+		key := listenKey{conn.Family, conn.Laddr.IP, conn.Laddr.Port}
+		if !listeners[key] {
+			for listener := range listeners {
+				if listener.family == key.family && listener.port == key.port && isWildcardIP(listener.ip) {
+					key = listener
+					break
+				}
+			}
+		}
+		if listeners[key] {
+			established[key]++
+		}
+	}
 
-		portsMap := make(map[string]struct{})
-		for _, conn := range connections {
-			if conn.Status == "LISTEN" {
-				port := fmt.Sprintf("%d", conn.Laddr.Port)
-				portsMap[port] = struct{}{}
+	seen := make(map[SocketInfo]struct{})
+	var sockets []SocketInfo
+	for _, conn := range connections {
+		var info SocketInfo
+		switch {
+		case conn.Family == syscall.AF_UNIX:
+			if conn.Laddr.IP == "" {
+				continue
+			}
+			info = SocketInfo{Proto: "unix", Bind: conn.Laddr.IP, State: "BOUND"}
+			if conn.Type == syscall.SOCK_DGRAM {
+				info.Proto = "unixgram"
+			}
+		case conn.Type == syscall.SOCK_STREAM:
+			if conn.Status != "LISTEN" {
+				continue
 			}
+			info = SocketInfo{Proto: "tcp", Bind: conn.Laddr.IP, Port: conn.Laddr.Port, State: conn.Status}
+			info.Established = established[listenKey{conn.Family, conn.Laddr.IP, conn.Laddr.Port}]
+		case conn.Type == syscall.SOCK_DGRAM:
+			if conn.Laddr.Port == 0 {
+				continue
+			}
+			info = SocketInfo{Proto: "udp", Bind: conn.Laddr.IP, Port: conn.Laddr.Port, State: "BOUND"}
+		default:
+			continue
+		}
+		if conn.Family == syscall.AF_INET6 {
+			info.Proto += "6"
 		}
+		if _, ok := seen[info]; ok {
+			continue
+		}
+		seen[info] = struct{}{}
+		sockets = append(sockets, info)
+	}
 
-		if len(portsMap) == 0 {
-			PrintGreen(fmt.Sprintf("Cmdline: %s, PID: %d is not listening on any ports.", cmdline, pid))
-		} else {
-			ports := make([]string, 0, len(portsMap))
-			for port := range portsMap {
-				ports = append(ports, port)
+	sort.Slice(sockets, func(i, j int) bool {
+		if sockets[i].Proto != sockets[j].Proto {
+			return sockets[i].Proto < sockets[j].Proto
+		}
+		if sockets[i].Port != sockets[j].Port {
+			return sockets[i].Port < sockets[j].Port
+		}
+		return sockets[i].Bind < sockets[j].Bind
+	})
+	return sockets
+}
+
+// PrintSocketTable prints the sockets as an aligned table grouped by service and instance.
+func PrintSocketTable(instances []InstanceSockets) {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tINSTANCE\tPID\tPROTO\tBIND\tPORT\tSTATE\tESTABLISHED")
+
+	for _, inst := range instances {
+		prefix := fmt.Sprintf("%s\t%s\t%d", inst.Service, inst.Instance, inst.PID)
+		if inst.Err != nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t%s\t-\n", prefix, inst.Err.Error())
+			continue
+		}
+		if len(inst.Sockets) == 0 {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\tNO SOCKETS\t-\n", prefix)
+			continue
+		}
+		for i, sock := range inst.Sockets {
+			if i > 0 {
+				prefix = "\t\t"
+			}
+			port := "-"
+			if sock.Port != 0 {
+				port = strconv.FormatUint(uint64(sock.Port), 10)
 			}
-			PrintGreen(fmt.Sprintf("Cmdline: %s, PID: %d is listening on ports: %s", cmdline, pid, strings.Join(ports, ", ")))
+			conns := "-"
+			if sock.Proto == "tcp" || sock.Proto == "tcp6" {
+				conns = strconv.Itoa(sock.Established)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", prefix, sock.Proto, sock.Bind, port, sock.State, conns)
 		}
 	}
+	_ = tw.Flush()
+
+	_, _ = Print(PrintOptions{Color: ColorGreen, Message: b.String(), NoNewLine: true})
+}
+
+// PrintBinaryPorts prints the socket table of every running process of the binary.
+func PrintBinaryPorts(binaryPath string, pidMap map[string][]int) {
+	pids, exists := pidMap[binaryPath]
+	if !exists || len(pids) == 0 {
+		fmt.Printf("No running processes found for binary: %s\n", binaryPath)
+		return
+	}
+	PrintSocketTable(CollectBinarySockets(binaryPath, pidMap))
 }
 
 func BatchKillExistBinaries(binaryPaths []string) {
@@ -216,16 +383,9 @@ func KillExistBinary(binaryPath string) {
 	}
 }
 
-// DetectPlatform detects the operating system and architecture.
+// DetectPlatform returns the platform of the host in goos_goarch form.
 func DetectPlatform() string {
-	targetOS, targetArch := runtime.GOOS, runtime.GOARCH
-	switch targetArch {
-	case "amd64", "arm64":
-	default:
-		fmt.Printf("Unsupported architecture: %s\n", targetArch)
-		os.Exit(1)
-	}
-	return fmt.Sprintf("%s_%s", targetOS, targetArch)
+	return runtime.GOOS + "_" + runtime.GOARCH
 }
 
 // rootDir gets the absolute path of the current directory.
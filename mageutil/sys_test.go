@@ -0,0 +1,84 @@
+package mageutil
+
+import (
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func tcpConn(family uint32, ip string, port uint32, status string) net.ConnectionStat {
+	return net.ConnectionStat{Family: family, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: ip, Port: port}, Status: status}
+}
+
+func TestSummarizeSockets(t *testing.T) {
+	tests := []struct {
+		name        string
+		connections []net.ConnectionStat
+		want        []SocketInfo
+	}{
+		{
+			name: "accepted on wildcard listener",
+			connections: []net.ConnectionStat{
+				tcpConn(syscall.AF_INET, "0.0.0.0", 10001, "LISTEN"),
+				tcpConn(syscall.AF_INET, "127.0.0.1", 10001, "ESTABLISHED"),
+				tcpConn(syscall.AF_INET, "10.0.0.5", 10001, "ESTABLISHED"),
+			},
+			want: []SocketInfo{{Proto: "tcp", Bind: "0.0.0.0", Port: 10001, State: "LISTEN", Established: 2}},
+		},
+		{
+			name: "tcp and tcp6 listeners on the same port",
+			connections: []net.ConnectionStat{
+				tcpConn(syscall.AF_INET, "0.0.0.0", 10001, "LISTEN"),
+				tcpConn(syscall.AF_INET6, "::", 10001, "LISTEN"),
+				tcpConn(syscall.AF_INET6, "::1", 10001, "ESTABLISHED"),
+			},
+			want: []SocketInfo{
+				{Proto: "tcp", Bind: "0.0.0.0", Port: 10001, State: "LISTEN"},
+				{Proto: "tcp6", Bind: "::", Port: 10001, State: "LISTEN", Established: 1},
+			},
+		},
+		{
+			name: "listeners on different addresses of the same port",
+			connections: []net.ConnectionStat{
+				tcpConn(syscall.AF_INET, "127.0.0.1", 10001, "LISTEN"),
+				tcpConn(syscall.AF_INET, "10.0.0.5", 10001, "LISTEN"),
+				tcpConn(syscall.AF_INET, "10.0.0.5", 10001, "ESTABLISHED"),
+			},
+			want: []SocketInfo{
+				{Proto: "tcp", Bind: "10.0.0.5", Port: 10001, State: "LISTEN", Established: 1},
+				{Proto: "tcp", Bind: "127.0.0.1", Port: 10001, State: "LISTEN"},
+			},
+		},
+		{
+			name: "outbound connection from a listening port number",
+			connections: []net.ConnectionStat{
+				tcpConn(syscall.AF_INET, "127.0.0.1", 10001, "LISTEN"),
+				tcpConn(syscall.AF_INET, "10.0.0.5", 10001, "ESTABLISHED"),
+				tcpConn(syscall.AF_INET, "10.0.0.5", 43210, "ESTABLISHED"),
+			},
+			want: []SocketInfo{{Proto: "tcp", Bind: "127.0.0.1", Port: 10001, State: "LISTEN"}},
+		},
+		{
+			name: "udp and unix sockets",
+			connections: []net.ConnectionStat{
+				{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Laddr: net.Addr{IP: "0.0.0.0", Port: 5353}},
+				{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Laddr: net.Addr{IP: "0.0.0.0"}},
+				{Family: syscall.AF_UNIX, Type: syscall.SOCK_STREAM, Laddr: net.Addr{IP: "/tmp/app.sock"}},
+				{Family: syscall.AF_UNIX, Type: syscall.SOCK_STREAM},
+			},
+			want: []SocketInfo{
+				{Proto: "udp", Bind: "0.0.0.0", Port: 5353, State: "BOUND"},
+				{Proto: "unix", Bind: "/tmp/app.sock", State: "BOUND"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeSockets(tt.connections); !slices.Equal(got, tt.want) {
+				t.Errorf("summarizeSockets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
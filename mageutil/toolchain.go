@@ -0,0 +1,87 @@
+package mageutil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PlatformToolchain is the C toolchain used for cgo when building one platform, declared in the
+// build.platforms section of start-config.yml. Values may reference environment variables.
+type PlatformToolchain struct {
+	CC       string            `yaml:"cc"`  // C compiler command, e.g. "zig cc -target x86_64-linux-musl"
+	CXX      string            `yaml:"cxx"` // C++ compiler command
+	Sysroot  string            `yaml:"sysroot"`
+	CFlags   string            `yaml:"cflags"`   // Added to CGO_CFLAGS
+	CXXFlags string            `yaml:"cxxflags"` // Added to CGO_CXXFLAGS
+	LDFlags  string            `yaml:"ldflags"`  // Added to CGO_LDFLAGS
+	Env      map[string]string `yaml:"env"`      // Extra environment variables, e.g. PKG_CONFIG_PATH
+}
+
+// platformToolchain returns the toolchain declared for the platform, falling back to the one of the
+// platform without its microarchitecture variant.
+func platformToolchain(platform string) (*PlatformToolchain, bool) {
+	if tc, ok := buildConfig.Platforms[platform]; ok {
+		return tc, true
+	}
+	tc, ok := buildConfig.Platforms[basePlatform(platform)]
+	return tc, ok
+}
+
+// toolchainEnv returns the environment variables configuring the C toolchain of the platform, or nil
+// if no toolchain is configured for it.
+func toolchainEnv(platform string) map[string]string {
+	tc, _ := platformToolchain(platform)
+	if tc == nil {
+		return nil
+	}
+
+	env := make(map[string]string)
+	for k, v := range tc.Env {
+		env[k] = os.ExpandEnv(v)
+	}
+	if tc.CC != "" {
+		env["CC"] = os.ExpandEnv(tc.CC)
+	}
+	if tc.CXX != "" {
+		env["CXX"] = os.ExpandEnv(tc.CXX)
+	}
+
+	var sysroot string
+	if tc.Sysroot != "" {
+		sysroot = "--sysroot=" + os.ExpandEnv(tc.Sysroot)
+	}
+	for key, flags := range map[string]string{
+		"CGO_CFLAGS":   tc.CFlags,
+		"CGO_CXXFLAGS": tc.CXXFlags,
+		"CGO_LDFLAGS":  tc.LDFlags,
+	} {
+		// Flags from the process environment are kept, the configured ones are appended.
+		if value := joinFlags(os.Getenv(key), sysroot, os.ExpandEnv(flags)); value != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// checkCgoToolchain warns when cgo is explicitly enabled for a cross-compile without a configured
+// C toolchain, which go build cannot do with the host compiler.
+func checkCgoToolchain(platform, cgoEnabled string) {
+	if cgoEnabled != "1" || basePlatform(platform) == DetectPlatform() {
+		return
+	}
+	if _, ok := platformToolchain(platform); ok || os.Getenv("CC") != "" {
+		return
+	}
+	PrintYellow(fmt.Sprintf("CGO_ENABLED=1 for %s without a C toolchain, declare one in build.platforms.%s of %s", platform, platform, StartConfigFile))
+}
+
+func joinFlags(flags ...string) string {
+	var parts []string
+	for _, f := range flags {
+		if f = strings.TrimSpace(f); f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,91 @@
+package mageutil
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultVersionPackage = "main"
+
+// VersionInfo is the git metadata stamped into binaries with -ldflags -X. The variables
+// Version, GitCommit, GitDirty and BuildTime of the version package receive these values.
+type VersionInfo struct {
+	Tag       string `json:"tag,omitempty"` // Tag pointing at HEAD, empty if HEAD is not tagged
+	Commit    string `json:"commit"`
+	Dirty     bool   `json:"dirty"` // Whether tracked files have uncommitted changes
+	BuildTime string `json:"buildTime"`
+}
+
+// ResolveVersionInfo reads the version metadata from the git repository in dir.
+func ResolveVersionInfo(dir string) (*VersionInfo, error) {
+	commit, err := gitOutput(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	status, err := gitOutput(dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	// A failure here only means HEAD is not tagged.
+	tag, _ := gitOutput(dir, "describe", "--tags", "--exact-match", "HEAD")
+
+	return &VersionInfo{
+		Tag:       tag,
+		Commit:    commit,
+		Dirty:     status != "",
+		BuildTime: time.Now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// Label returns a short version label, the tag or the abbreviated commit, with a -dirty suffix
+// for uncommitted changes.
+func (v *VersionInfo) Label() string {
+	label := v.Tag
+	if label == "" {
+		label = v.Commit
+		if len(label) > 12 {
+			label = label[:12]
+		}
+	}
+	if v.Dirty {
+		label += "-dirty"
+	}
+	return label
+}
+
+// LDFlags returns the -X flags that stamp the version into pkg. The build time is left out when
+// withBuildTime is false, so the flags stay stable between builds of the same commit.
+func (v *VersionInfo) LDFlags(pkg string, withBuildTime bool) []string {
+	version := v.Tag
+	if version == "" {
+		version = v.Label()
+	}
+	flags := []string{
+		fmt.Sprintf("-X %s.Version=%s", pkg, version),
+		fmt.Sprintf("-X %s.GitCommit=%s", pkg, v.Commit),
+		fmt.Sprintf("-X %s.GitDirty=%s", pkg, strconv.FormatBool(v.Dirty)),
+	}
+	if withBuildTime {
+		flags = append(flags, fmt.Sprintf("-X %s.BuildTime=%s", pkg, v.BuildTime))
+	}
+	return flags
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), msg)
+		}
+		return "", fmt.Errorf("git %s failed: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,158 @@
+package mageutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Workspace is the go.work workspace active in the root directory.
+type Workspace struct {
+	File    string            // Path of the go.work file
+	Modules []WorkspaceModule // Sorted by directory, the root module first
+}
+
+type WorkspaceModule struct {
+	Dir  string // Root-relative directory, "." for the root module
+	Path string // Module path declared in go.mod
+}
+
+var (
+	workspaceMu    sync.Mutex
+	workspaceCache = make(map[string]*Workspace) // Keyed by root directory
+)
+
+// currentWorkspace returns the workspace of the root directory, or nil if no go.work is active.
+// The result is cached per root directory.
+func currentWorkspace() *Workspace {
+	workspaceMu.Lock()
+	defer workspaceMu.Unlock()
+
+	root := filepath.Clean(Paths.Root)
+	if ws, ok := workspaceCache[root]; ok {
+		return ws
+	}
+	ws, err := loadWorkspace(root)
+	if err != nil {
+		PrintYellow(fmt.Sprintf("Ignoring go.work: %v", err))
+	}
+	workspaceCache[root] = ws
+	return ws
+}
+
+// loadWorkspace reads the go.work file selected by `go env GOWORK` in root. GOWORK=off disables it.
+func loadWorkspace(root string) (*Workspace, error) {
+	cmd := exec.Command("go", "env", "GOWORK")
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve GOWORK: %v", err)
+	}
+	file := strings.TrimSpace(string(output))
+	if file == "" || file == "off" {
+		return nil, nil
+	}
+
+	cmd = exec.Command("go", "work", "edit", "-json", file)
+	cmd.Dir = root
+	output, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	var goWork struct {
+		Use []struct {
+			DiskPath   string
+			ModulePath string
+		}
+	}
+	if err := json.Unmarshal(output, &goWork); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+	}
+
+	ws := &Workspace{File: file}
+	for _, use := range goWork.Use {
+		dir := use.DiskPath
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(file), dir)
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || !isSubPath(root, dir) {
+			PrintYellow(fmt.Sprintf("Workspace module %s is outside of %s, its binaries are not built", dir, root))
+			continue
+		}
+		modPath := use.ModulePath
+		if modPath == "" {
+			modPath = readModulePath(dir)
+		}
+		ws.Modules = append(ws.Modules, WorkspaceModule{Dir: rel, Path: modPath})
+	}
+	sort.Slice(ws.Modules, func(i, j int) bool {
+		if ws.Modules[i].Dir == "." || ws.Modules[j].Dir == "." {
+			return ws.Modules[i].Dir == "."
+		}
+		return ws.Modules[i].Dir < ws.Modules[j].Dir
+	})
+	return ws, nil
+}
+
+// moduleFor returns the workspace module containing the root-relative path, the deepest one wins.
+func (ws *Workspace) moduleFor(path string) (WorkspaceModule, bool) {
+	var found WorkspaceModule
+	var ok bool
+	for _, mod := range ws.Modules {
+		if (mod.Dir == "." || isSubPath(mod.Dir, path)) && (!ok || len(mod.Dir) > len(found.Dir)) {
+			found, ok = mod, true
+		}
+	}
+	return found, ok
+}
+
+// binaryModuleDir returns the root-relative directory of the workspace module containing the binary,
+// "." for the root module and outside of a workspace.
+func binaryModuleDir(binary string) string {
+	ws := currentWorkspace()
+	if ws == nil {
+		return "."
+	}
+	if mod, ok := ws.moduleFor(binary); ok {
+		return mod.Dir
+	}
+	return "."
+}
+
+// moduleRelPath returns the path of the binary relative to its workspace module.
+func moduleRelPath(binary string) string {
+	modDir := binaryModuleDir(binary)
+	if modDir == "." {
+		return binary
+	}
+	rel, err := filepath.Rel(modDir, binary)
+	if err != nil {
+		return binary
+	}
+	return rel
+}
+
+// readModulePath returns the module path declared in the go.mod of dir.
+func readModulePath(dir string) string {
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}